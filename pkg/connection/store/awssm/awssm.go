@@ -0,0 +1,224 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package awssm implements a connection secret store backed by AWS Secrets
+// Manager.
+package awssm
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+const (
+	errNoConfig      = "aws secrets manager config is missing"
+	errLoadConfig    = "cannot load aws config"
+	errGetSecret     = "cannot get secret"
+	errPutSecret     = "cannot put secret"
+	errDeleteSecret  = "cannot delete secret"
+	errTagSecret     = "cannot tag secret"
+	errParseMetadata = "cannot parse secret metadata"
+	errParsePayload  = "cannot parse secret payload"
+)
+
+func init() {
+	store.Register(v1.SecretStoreAWSSecretsManager, func(ctx context.Context, _ resource.ClientApplicator, cfg v1.SecretStoreConfig) (store.SecretStore, error) {
+		return NewSecretStore(ctx, cfg)
+	})
+}
+
+// client is the subset of the AWS Secrets Manager API used by SecretStore.
+type client interface {
+	GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, in *secretsmanager.PutSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	CreateSecret(ctx context.Context, in *secretsmanager.CreateSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	TagResource(ctx context.Context, in *secretsmanager.TagResourceInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.TagResourceOutput, error)
+	DeleteSecret(ctx context.Context, in *secretsmanager.DeleteSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error)
+}
+
+// metadata is the shape of a store.Secret's opaque Metadata blob for the
+// AWS Secrets Manager store.
+type metadata struct {
+	Tags map[string]string `json:"labels,omitempty"`
+}
+
+// SecretStore is a connection SecretStore that stores secrets as AWS
+// Secrets Manager secrets.
+type SecretStore struct {
+	client client
+}
+
+// NewSecretStore returns a new SecretStore that talks to the AWS Secrets
+// Manager endpoint described by cfg.AWSSecretsManager.
+func NewSecretStore(ctx context.Context, cfg v1.SecretStoreConfig) (*SecretStore, error) {
+	if cfg.AWSSecretsManager == nil {
+		return nil, errors.New(errNoConfig)
+	}
+
+	acfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.AWSSecretsManager.Region))
+	if err != nil {
+		return nil, errors.Wrap(err, errLoadConfig)
+	}
+
+	return &SecretStore{client: secretsmanager.NewFromConfig(acfg)}, nil
+}
+
+func name(s store.Secret) string {
+	if s.Scope != "" {
+		return s.Scope + "/" + s.Name
+	}
+	return s.Name
+}
+
+// ReadKeyValues reads all key value pairs of the given secret. AWS Secrets
+// Manager does not yet support reading a historical revision through this
+// store, so any ReadOption is ignored.
+func (ss *SecretStore) ReadKeyValues(ctx context.Context, s store.Secret, _ ...store.ReadOption) (store.KeyValues, error) {
+	out, err := ss.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(name(s))})
+	if err != nil {
+		return nil, errors.Wrap(err, errGetSecret)
+	}
+
+	kv := store.KeyValues{}
+	if out.SecretString != nil {
+		if err := json.Unmarshal([]byte(*out.SecretString), &kv); err != nil {
+			return nil, errors.Wrap(err, errParsePayload)
+		}
+	}
+	return kv, nil
+}
+
+// WriteKeyValues additively patches the secret with the supplied key value
+// pairs - any existing keys not present in kv are preserved - creating the
+// secret if it does not already exist, and tags it based on the secret's
+// Metadata.
+func (ss *SecretStore) WriteKeyValues(ctx context.Context, s store.Secret, kv store.KeyValues) error {
+	existing, err := ss.existingKeyValues(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	merged := make(store.KeyValues, len(existing)+len(kv))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range kv {
+		merged[k] = v
+	}
+
+	return ss.put(ctx, s, merged)
+}
+
+// existingKeyValues reads a secret's current key values, returning an empty
+// KeyValues (rather than an error) if the secret does not yet exist.
+func (ss *SecretStore) existingKeyValues(ctx context.Context, s store.Secret) (store.KeyValues, error) {
+	kv, err := ss.ReadKeyValues(ctx, s)
+	if err == nil {
+		return kv, nil
+	}
+	var nf *types.ResourceNotFoundException
+	if errors.As(err, &nf) {
+		return store.KeyValues{}, nil
+	}
+	return nil, err
+}
+
+// put overwrites the secret's data with exactly kv, creating it if it does
+// not already exist, and tags it based on the secret's Metadata. Callers
+// that need an additive patch should merge kv with the secret's existing
+// data first - see WriteKeyValues.
+func (ss *SecretStore) put(ctx context.Context, s store.Secret, kv store.KeyValues) error {
+	payload, err := json.Marshal(kv)
+	if err != nil {
+		return errors.Wrap(err, errParsePayload)
+	}
+
+	var tags []types.Tag
+	if len(s.Metadata) > 0 {
+		md := &metadata{}
+		if err := json.Unmarshal(s.Metadata, md); err != nil {
+			return errors.Wrap(err, errParseMetadata)
+		}
+		for k, v := range md.Tags {
+			tags = append(tags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+	}
+
+	if _, err := ss.client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name(s)),
+		SecretString: aws.String(string(payload)),
+	}); err != nil {
+		var nf *types.ResourceNotFoundException
+		if !errors.As(err, &nf) {
+			return errors.Wrap(err, errPutSecret)
+		}
+		if _, err := ss.client.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+			Name:         aws.String(name(s)),
+			SecretString: aws.String(string(payload)),
+			Tags:         tags,
+		}); err != nil {
+			return errors.Wrap(err, errPutSecret)
+		}
+		return nil
+	}
+
+	if len(tags) > 0 {
+		if _, err := ss.client.TagResource(ctx, &secretsmanager.TagResourceInput{SecretId: aws.String(name(s)), Tags: tags}); err != nil {
+			return errors.Wrap(err, errTagSecret)
+		}
+	}
+	return nil
+}
+
+// DeleteKeyValues deletes key value pairs of the given secret. If no kv is
+// supplied, the whole secret is scheduled for deletion; otherwise the
+// remaining keys are written back, and the secret is only deleted once it
+// has no keys remaining.
+func (ss *SecretStore) DeleteKeyValues(ctx context.Context, s store.Secret, kv store.KeyValues) error {
+	if len(kv) == 0 {
+		if _, err := ss.client.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{SecretId: aws.String(name(s))}); err != nil {
+			return errors.Wrap(err, errDeleteSecret)
+		}
+		return nil
+	}
+
+	existing, err := ss.ReadKeyValues(ctx, s)
+	if err != nil {
+		return errors.Wrap(err, errGetSecret)
+	}
+	for k := range kv {
+		delete(existing, k)
+	}
+
+	if len(existing) == 0 {
+		if _, err := ss.client.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{SecretId: aws.String(name(s))}); err != nil {
+			return errors.Wrap(err, errDeleteSecret)
+		}
+		return nil
+	}
+
+	return ss.put(ctx, s, existing)
+}
@@ -0,0 +1,379 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package awssm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var (
+	errBoom = errors.New("boom")
+
+	fakeSecretName = "fake"
+
+	fakeKV = map[string][]byte{
+		"key1": []byte("value1"),
+		"key2": []byte("value2"),
+		"key3": []byte("value3"),
+	}
+)
+
+type fakeClient struct {
+	MockGetSecretValue func(ctx context.Context, in *secretsmanager.GetSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	MockPutSecretValue func(ctx context.Context, in *secretsmanager.PutSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	MockCreateSecret   func(ctx context.Context, in *secretsmanager.CreateSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	MockTagResource    func(ctx context.Context, in *secretsmanager.TagResourceInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.TagResourceOutput, error)
+	MockDeleteSecret   func(ctx context.Context, in *secretsmanager.DeleteSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error)
+}
+
+func (f *fakeClient) GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	return f.MockGetSecretValue(ctx, in, opts...)
+}
+
+func (f *fakeClient) PutSecretValue(ctx context.Context, in *secretsmanager.PutSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	return f.MockPutSecretValue(ctx, in, opts...)
+}
+
+func (f *fakeClient) CreateSecret(ctx context.Context, in *secretsmanager.CreateSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+	return f.MockCreateSecret(ctx, in, opts...)
+}
+
+func (f *fakeClient) TagResource(ctx context.Context, in *secretsmanager.TagResourceInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.TagResourceOutput, error) {
+	return f.MockTagResource(ctx, in, opts...)
+}
+
+func (f *fakeClient) DeleteSecret(ctx context.Context, in *secretsmanager.DeleteSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error) {
+	return f.MockDeleteSecret(ctx, in, opts...)
+}
+
+func fakeSecretString(kv map[string][]byte) *string {
+	b, _ := json.Marshal(kv)
+	s := string(b)
+	return &s
+}
+
+func TestSecretStoreReadKeyValues(t *testing.T) {
+	type args struct {
+		client client
+		secret store.Secret
+	}
+	type want struct {
+		result store.KeyValues
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"CannotGetSecret": {
+			reason: "Should return a proper error if cannot get the secret",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return nil, errBoom
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetSecret),
+			},
+		},
+		"SuccessfulRead": {
+			reason: "Should return all key values after a successful read",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return &secretsmanager.GetSecretValueOutput{SecretString: fakeSecretString(fakeKV)}, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+			},
+			want: want{
+				result: store.KeyValues(fakeKV),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss := &SecretStore{client: tc.args.client}
+			got, err := ss.ReadKeyValues(context.Background(), tc.args.secret)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.ReadKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nss.ReadKeyValues(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretStoreWriteKeyValues(t *testing.T) {
+	type args struct {
+		client client
+		secret store.Secret
+		kv     store.KeyValues
+	}
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"PutFailed": {
+			reason: "Should return a proper error when the put fails for a reason other than the secret missing.",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return nil, &types.ResourceNotFoundException{}
+					},
+					MockPutSecretValue: func(_ context.Context, _ *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+						return nil, errBoom
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv:     store.KeyValues(fakeKV),
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errPutSecret),
+			},
+		},
+		"SecretCreatedIfMissing": {
+			reason: "Should create the secret if it doesn't already exist.",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return nil, &types.ResourceNotFoundException{}
+					},
+					MockPutSecretValue: func(_ context.Context, _ *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+						return nil, &types.ResourceNotFoundException{}
+					},
+					MockCreateSecret: func(_ context.Context, in *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+						want := fakeSecretString(fakeKV)
+						if diff := cmp.Diff(*want, *in.SecretString); diff != "" {
+							t.Errorf("r: -want, +got:\n%s", diff)
+						}
+						return &secretsmanager.CreateSecretOutput{}, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv:     store.KeyValues(fakeKV),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"SecretPatchedAdditively": {
+			reason: "Should merge new key values into any existing data rather than overwriting it.",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return &secretsmanager.GetSecretValueOutput{SecretString: fakeSecretString(map[string][]byte{
+							"existing-key": []byte("existing-value"),
+						})}, nil
+					},
+					MockPutSecretValue: func(_ context.Context, in *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+						want := fakeSecretString(map[string][]byte{
+							"existing-key": []byte("existing-value"),
+							"new-key":      []byte("new-value"),
+						})
+						if diff := cmp.Diff(*want, *in.SecretString); diff != "" {
+							t.Errorf("r: -want, +got:\n%s", diff)
+						}
+						return &secretsmanager.PutSecretValueOutput{}, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv: store.KeyValues(map[string][]byte{
+					"new-key": []byte("new-value"),
+				}),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"SecretTaggedOnCreate": {
+			reason: "Should tag a newly created secret based on its Metadata.",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return nil, &types.ResourceNotFoundException{}
+					},
+					MockPutSecretValue: func(_ context.Context, _ *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+						return nil, &types.ResourceNotFoundException{}
+					},
+					MockCreateSecret: func(_ context.Context, in *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+						want := []types.Tag{{Key: aws.String("owner"), Value: aws.String("team-a")}}
+						if diff := cmp.Diff(want, in.Tags); diff != "" {
+							t.Errorf("r: -want, +got:\n%s", diff)
+						}
+						return &secretsmanager.CreateSecretOutput{}, nil
+					},
+				},
+				secret: store.Secret{
+					Name:     fakeSecretName,
+					Metadata: []byte(`{"labels":{"owner":"team-a"}}`),
+				},
+				kv: store.KeyValues(fakeKV),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"SecretTaggedOnUpdate": {
+			reason: "Should tag an existing secret based on its Metadata.",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return &secretsmanager.GetSecretValueOutput{SecretString: fakeSecretString(fakeKV)}, nil
+					},
+					MockPutSecretValue: func(_ context.Context, _ *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+						return &secretsmanager.PutSecretValueOutput{}, nil
+					},
+					MockTagResource: func(_ context.Context, in *secretsmanager.TagResourceInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.TagResourceOutput, error) {
+						want := []types.Tag{{Key: aws.String("owner"), Value: aws.String("team-a")}}
+						if diff := cmp.Diff(want, in.Tags); diff != "" {
+							t.Errorf("r: -want, +got:\n%s", diff)
+						}
+						return &secretsmanager.TagResourceOutput{}, nil
+					},
+				},
+				secret: store.Secret{
+					Name:     fakeSecretName,
+					Metadata: []byte(`{"labels":{"owner":"team-a"}}`),
+				},
+				kv: store.KeyValues(fakeKV),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss := &SecretStore{client: tc.args.client}
+			err := ss.WriteKeyValues(context.Background(), tc.args.secret, tc.args.kv)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.WriteKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretStoreDeleteKeyValues(t *testing.T) {
+	type args struct {
+		client client
+		secret store.Secret
+		kv     store.KeyValues
+	}
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"DeleteWholeSecret": {
+			reason: "Should delete the whole secret if no kv supplied.",
+			args: args{
+				client: &fakeClient{
+					MockDeleteSecret: func(_ context.Context, _ *secretsmanager.DeleteSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error) {
+						return &secretsmanager.DeleteSecretOutput{}, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"SecretWrittenBackWithRemainingKeysOnly": {
+			reason: "Should write the remaining keys back without merging in the deleted ones.",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return &secretsmanager.GetSecretValueOutput{SecretString: fakeSecretString(fakeKV)}, nil
+					},
+					MockPutSecretValue: func(_ context.Context, in *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+						want := fakeSecretString(map[string][]byte{"key3": []byte("value3")})
+						if diff := cmp.Diff(*want, *in.SecretString); diff != "" {
+							t.Errorf("r: -want, +got:\n%s", diff)
+						}
+						return &secretsmanager.PutSecretValueOutput{}, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv: store.KeyValues(map[string][]byte{
+					"key1": []byte("value1"),
+					"key2": []byte("value2"),
+				}),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"WholeSecretDeletedWhenNoKeysRemain": {
+			reason: "Should delete the whole secret once its last remaining key is removed.",
+			args: args{
+				client: &fakeClient{
+					MockGetSecretValue: func(_ context.Context, _ *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+						return &secretsmanager.GetSecretValueOutput{SecretString: fakeSecretString(map[string][]byte{
+							"key1": []byte("value1"),
+						})}, nil
+					},
+					MockDeleteSecret: func(_ context.Context, _ *secretsmanager.DeleteSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error) {
+						return &secretsmanager.DeleteSecretOutput{}, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv: store.KeyValues(map[string][]byte{
+					"key1": []byte("value1"),
+				}),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss := &SecretStore{client: tc.args.client}
+			err := ss.DeleteKeyValues(context.Background(), tc.args.secret, tc.args.kv)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.DeleteKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
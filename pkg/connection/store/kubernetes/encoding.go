@@ -0,0 +1,94 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/fxamacker/cbor/v2"
+	corev1 "k8s.io/api/core/v1"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+)
+
+const (
+	// annotationKeyContentType records the wire format of a secret's
+	// payload, so that ReadKeyValues can auto-detect it.
+	annotationKeyContentType = "crossplane.io/content-type"
+
+	// contentTypeCBOR is the value of annotationKeyContentType for secrets
+	// whose payload is stored as a single CBOR-encoded blob.
+	contentTypeCBOR = "application/cbor"
+
+	// keyCBORPayload is the well-known Secret data key a CBOR-encoded
+	// payload is stored under, in place of one key per connection detail.
+	// Secret.Data keys are validated as config map keys, which don't allow
+	// '/', so this can't use the "crossplane.io/" prefix convention used by
+	// labels and annotations elsewhere in this package.
+	keyCBORPayload = "crossplane-io-cbor-payload"
+)
+
+// unmarshalMetadata parses a store.Secret's opaque Metadata blob into md,
+// using CBOR if enc requests it and JSON otherwise.
+func unmarshalMetadata(enc v1.SecretStoreConfigEncoding, data []byte, md *metadata) error {
+	if enc == v1.SecretStoreConfigEncodingCBOR {
+		return cbor.Unmarshal(data, md)
+	}
+	return json.Unmarshal(data, md)
+}
+
+// encodePayload replaces secret's per-key Data with a single CBOR-encoded
+// blob under keyCBORPayload, and marks it with the CBOR content-type
+// annotation so that decodePayload can recognise it on read. This trades
+// per-key visibility in `kubectl get secret -o yaml` for a more compact
+// representation of large, nested connection details.
+func encodePayload(secret *corev1.Secret, kv store.KeyValues) error {
+	payload, err := cbor.Marshal(map[string][]byte(kv))
+	if err != nil {
+		return err
+	}
+
+	secret.Data = map[string][]byte{keyCBORPayload: payload}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[annotationKeyContentType] = contentTypeCBOR
+
+	return nil
+}
+
+// decodePayload returns the key values encoded in secret's CBOR payload, if
+// any. The returned bool reports whether secret was recognised as carrying
+// a CBOR payload at all; callers should fall back to treating secret.Data
+// as the key values directly when it's false.
+func decodePayload(secret *corev1.Secret) (store.KeyValues, bool, error) {
+	if secret.Annotations[annotationKeyContentType] != contentTypeCBOR {
+		return nil, false, nil
+	}
+
+	payload, ok := secret.Data[keyCBORPayload]
+	if !ok {
+		return nil, false, nil
+	}
+
+	kv := store.KeyValues{}
+	if err := cbor.Unmarshal(payload, &kv); err != nil {
+		return nil, true, err
+	}
+	return kv, true, nil
+}
@@ -0,0 +1,180 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+// TestSecretStoreCBORRoundtrip mirrors TestSecretStoreWriteKeyValues' case
+// "SecretCreatedWithDataAndMetadata", but with the store configured to use
+// CBOR encoding: the written secret should carry a single CBOR payload key
+// rather than one key per connection detail, and reading it back should
+// transparently decode it again.
+func TestSecretStoreCBORRoundtrip(t *testing.T) {
+	md, err := cbor.Marshal(map[string]interface{}{
+		"labels":      fakeLabels,
+		"annotations": fakeAnnotations,
+		"type":        string(corev1.SecretTypeOpaque),
+	})
+	if err != nil {
+		t.Fatalf("cbor.Marshal(...): %v", err)
+	}
+
+	var written *corev1.Secret
+	ss := &SecretStore{
+		encoding: v1.SecretStoreConfigEncodingCBOR,
+		client: resource.ClientApplicator{
+			Applicator: resource.ApplyFn(func(_ context.Context, obj client.Object, _ ...resource.ApplyOption) error {
+				written = obj.(*corev1.Secret)
+				return nil
+			}),
+		},
+	}
+
+	in := store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace, Metadata: md}
+	if err := ss.WriteKeyValues(context.Background(), in, store.KeyValues(fakeKV)); err != nil {
+		t.Fatalf("ss.WriteKeyValues(...): unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(contentTypeCBOR, written.Annotations[annotationKeyContentType]); diff != "" {
+		t.Errorf("content-type annotation: -want, +got:\n%s", diff)
+	}
+	if _, ok := written.Data[keyCBORPayload]; !ok {
+		t.Fatalf("expected secret data to contain key %q", keyCBORPayload)
+	}
+	if diff := cmp.Diff(fakeLabels, written.Labels); diff != "" {
+		t.Errorf("labels: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff(corev1.SecretTypeOpaque, written.Type); diff != "" {
+		t.Errorf("type: -want, +got:\n%s", diff)
+	}
+
+	ss.client = resource.ClientApplicator{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+				*obj.(*corev1.Secret) = *written
+				return nil
+			}),
+		},
+	}
+
+	got, err := ss.ReadKeyValues(context.Background(), in)
+	if err != nil {
+		t.Fatalf("ss.ReadKeyValues(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(store.KeyValues(fakeKV), got); diff != "" {
+		t.Errorf("ss.ReadKeyValues(...): -want, +got:\n%s", diff)
+	}
+}
+
+// TestSecretStoreCBORDelete proves that DeleteKeyValues decodes a
+// CBOR-encoded secret before removing keys from it, rather than matching
+// kv against the single opaque keyCBORPayload entry.
+func TestSecretStoreCBORDelete(t *testing.T) {
+	cborSecret := func(kv store.KeyValues) *corev1.Secret {
+		s := fakeConnectionSecret(withAnnotations(map[string]string{annotationKeyContentType: contentTypeCBOR}))
+		if err := encodePayload(s, kv); err != nil {
+			t.Fatalf("encodePayload(...): %v", err)
+		}
+		return s
+	}
+
+	t.Run("SomeKeysRemain", func(t *testing.T) {
+		var updated *corev1.Secret
+		ss := &SecretStore{
+			client: resource.ClientApplicator{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						*obj.(*corev1.Secret) = *cborSecret(fakeKV)
+						return nil
+					}),
+					MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+						updated = obj.(*corev1.Secret)
+						return nil
+					},
+				},
+			},
+		}
+
+		err := ss.DeleteKeyValues(context.Background(), store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace}, store.KeyValues(map[string][]byte{
+			"key1": []byte("value1"),
+			"key2": []byte("value2"),
+		}))
+		if err != nil {
+			t.Fatalf("ss.DeleteKeyValues(...): unexpected error: %v", err)
+		}
+
+		got, _, err := decodePayload(updated)
+		if err != nil {
+			t.Fatalf("decodePayload(...): %v", err)
+		}
+		if diff := cmp.Diff(store.KeyValues{"key3": []byte("value3")}, got); diff != "" {
+			t.Errorf("decodePayload(...): -want, +got:\n%s", diff)
+		}
+	})
+
+	t.Run("LastKeyRemoved", func(t *testing.T) {
+		deleted := false
+		ss := &SecretStore{
+			client: resource.ClientApplicator{
+				Client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						*obj.(*corev1.Secret) = *cborSecret(store.KeyValues{"key1": []byte("value1")})
+						return nil
+					}),
+					MockDelete: func(_ context.Context, _ client.Object, _ ...client.DeleteOption) error {
+						deleted = true
+						return nil
+					},
+				},
+			},
+		}
+
+		err := ss.DeleteKeyValues(context.Background(), store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace}, store.KeyValues(map[string][]byte{
+			"key1": []byte("value1"),
+		}))
+		if err != nil {
+			t.Fatalf("ss.DeleteKeyValues(...): unexpected error: %v", err)
+		}
+		if !deleted {
+			t.Error("expected the secret to be deleted once its last CBOR-encoded key was removed")
+		}
+	})
+}
+
+// TestKeyCBORPayloadIsValidSecretDataKey proves that keyCBORPayload can
+// actually be used as a key in a Kubernetes Secret's Data, which the API
+// server validates as a config map key (alphanumerics, '-', '_' and '.'
+// only - no '/').
+func TestKeyCBORPayloadIsValidSecretDataKey(t *testing.T) {
+	if errs := validation.IsConfigMapKey(keyCBORPayload); len(errs) > 0 {
+		t.Errorf("validation.IsConfigMapKey(%q): %v", keyCBORPayload, errs)
+	}
+}
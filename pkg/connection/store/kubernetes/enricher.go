@@ -0,0 +1,196 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errGetOwner         = "cannot get secret owner"
+	errGetTopLevelOwner = "cannot get top-level secret owner"
+
+	// LabelOwnerKind and LabelOwnerName are stamped on a connection secret
+	// by CachedEnricher, identifying the top-level owner of its nearest
+	// controlling owner reference.
+	LabelOwnerKind = "crossplane.io/owner-kind"
+	LabelOwnerName = "crossplane.io/owner-name"
+
+	// propagateLabelPrefix marks a label on an owning Composite or Claim
+	// that CachedEnricher should copy onto the connection secret, minus
+	// the prefix itself.
+	propagateLabelPrefix = "crossplane.io/propagate-"
+
+	// maxOwnerChainDepth bounds how many indirections Enrich will follow,
+	// as a backstop in addition to the per-owner (kind/name) dedup below.
+	maxOwnerChainDepth = 100
+)
+
+// indirectOwner resolves the owner one level further up the chain than ref,
+// e.g. a ReplicaSet's owning Deployment, or a Job's owning CronJob. It
+// returns a nil reference if ref has no such indirection, or isn't owned by
+// anything itself.
+type indirectOwner func(ctx context.Context, c client.Reader, namespace string, ref metav1.OwnerReference) (*metav1.OwnerReference, error)
+
+var defaultIndirectOwners = map[string]indirectOwner{
+	"ReplicaSet": func(ctx context.Context, c client.Reader, namespace string, ref metav1.OwnerReference) (*metav1.OwnerReference, error) {
+		rs := &appsv1.ReplicaSet{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, rs); err != nil {
+			return nil, err
+		}
+		return controllerOf(rs.OwnerReferences), nil
+	},
+	"Job": func(ctx context.Context, c client.Reader, namespace string, ref metav1.OwnerReference) (*metav1.OwnerReference, error) {
+		j := &batchv1.Job{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, j); err != nil {
+			return nil, err
+		}
+		return controllerOf(j.OwnerReferences), nil
+	},
+}
+
+// ownerKey identifies ref for the purposes of cycle detection while walking
+// an owner chain. It's keyed on kind and name rather than UID, since a
+// reference's UID isn't always populated (e.g. by older controllers).
+func ownerKey(ref metav1.OwnerReference) string {
+	return ref.Kind + "/" + ref.Name
+}
+
+func controllerOf(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	return nil
+}
+
+// CachedEnricher is a store.Enricher that uses a manager's cache to walk a
+// connection secret's owner chain, stamping it with the top-level owner's
+// kind and name, and propagating selected labels from the owner.
+type CachedEnricher struct {
+	reader   client.Reader
+	indirect map[string]indirectOwner
+}
+
+// NewCachedEnricher returns a CachedEnricher backed by mgr's cache, with
+// ReplicaSet -> Deployment and Job -> CronJob indirection wired in.
+func NewCachedEnricher(mgr manager.Manager) *CachedEnricher {
+	return &CachedEnricher{
+		reader:   mgr.GetCache(),
+		indirect: defaultIndirectOwners,
+	}
+}
+
+// Enrich resolves s's top-level owner and returns the labels that should be
+// stamped onto its secret. It is a no-op (returning no labels, no error) for
+// secrets that don't exist yet or have no controlling owner reference.
+func (e *CachedEnricher) Enrich(ctx context.Context, s store.Secret) (map[string]string, error) {
+	secret := &corev1.Secret{}
+	if err := e.reader.Get(ctx, client.ObjectKey{Namespace: s.Scope, Name: s.Name}, secret); err != nil {
+		// A secret that doesn't exist yet has no owner chain to walk. It
+		// will be enriched on its next update, once it's owned by
+		// something.
+		return nil, nil
+	}
+
+	owner := controllerOf(secret.OwnerReferences)
+	if owner == nil {
+		return nil, nil
+	}
+
+	seen := map[string]bool{ownerKey(*owner): true}
+	for depth := 0; depth < maxOwnerChainDepth; depth++ {
+		next := e.indirect[owner.Kind]
+		if next == nil {
+			next = e.genericIndirectOwner
+		}
+		indirect, err := next(ctx, e.reader, secret.Namespace, *owner)
+		if err != nil {
+			return nil, errors.Wrap(err, errGetOwner)
+		}
+		if indirect == nil || seen[ownerKey(*indirect)] {
+			break
+		}
+		seen[ownerKey(*indirect)] = true
+		owner = indirect
+	}
+
+	labels := map[string]string{
+		LabelOwnerKind: owner.Kind,
+		LabelOwnerName: owner.Name,
+	}
+
+	propagated, err := e.propagatedLabels(ctx, secret.Namespace, *owner)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetTopLevelOwner)
+	}
+	for k, v := range propagated {
+		labels[k] = v
+	}
+
+	return labels, nil
+}
+
+// genericIndirectOwner is the indirectOwner fallback used for any kind not
+// special-cased in e.indirect. It re-fetches ref generically (without
+// requiring a registered Go type) and returns its own controlling owner
+// reference, if any - so the walk keeps climbing arbitrary owner chains
+// (e.g. a managed resource's owning Composite, or a Composite's owning
+// Claim) instead of stopping at the first unrecognised kind.
+func (e *CachedEnricher) genericIndirectOwner(ctx context.Context, c client.Reader, namespace string, ref metav1.OwnerReference) (*metav1.OwnerReference, error) {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(ref.APIVersion)
+	u.SetKind(ref.Kind)
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, u); err != nil {
+		return nil, err
+	}
+	return controllerOf(u.GetOwnerReferences()), nil
+}
+
+// propagatedLabels reads owner generically (without requiring a registered
+// Go type) and returns any of its labels prefixed with propagateLabelPrefix,
+// stripped of that prefix - e.g. so a Composite or Claim can opt specific
+// labels into its managed resources' connection secrets.
+func (e *CachedEnricher) propagatedLabels(ctx context.Context, namespace string, owner metav1.OwnerReference) (map[string]string, error) {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(owner.APIVersion)
+	u.SetKind(owner.Kind)
+	if err := e.reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: owner.Name}, u); err != nil {
+		return nil, err
+	}
+
+	out := map[string]string{}
+	for k, v := range u.GetLabels() {
+		if rest, ok := strings.CutPrefix(k, propagateLabelPrefix); ok {
+			out[rest] = v
+		}
+	}
+	return out, nil
+}
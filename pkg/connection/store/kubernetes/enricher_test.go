@@ -0,0 +1,284 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+type fakeEnricher struct {
+	labels map[string]string
+	err    error
+}
+
+func (e *fakeEnricher) Enrich(_ context.Context, _ store.Secret) (map[string]string, error) {
+	return e.labels, e.err
+}
+
+// TestSecretStoreWriteKeyValuesWithEnricher proves that labels returned by a
+// configured Enricher end up on the applied secret, merged with anything
+// already set from the secret's Metadata.
+func TestSecretStoreWriteKeyValuesWithEnricher(t *testing.T) {
+	var applied *corev1.Secret
+
+	ss, err := NewSecretStore(context.Background(), resource.ClientApplicator{
+		Applicator: resource.ApplyFn(func(_ context.Context, obj client.Object, _ ...resource.ApplyOption) error {
+			applied = obj.(*corev1.Secret)
+			return nil
+		}),
+	}, v1.SecretStoreConfig{}, WithEnricher(&fakeEnricher{labels: map[string]string{
+		LabelOwnerKind: "Deployment",
+		LabelOwnerName: "example",
+	}}))
+	if err != nil {
+		t.Fatalf("NewSecretStore(...): unexpected error: %v", err)
+	}
+
+	in := store.Secret{
+		Name:     fakeSecretName,
+		Scope:    fakeSecretNamespace,
+		Metadata: []byte(`{"labels":{"environment":"unit-test"}}`),
+	}
+	if err := ss.WriteKeyValues(context.Background(), in, store.KeyValues(fakeKV)); err != nil {
+		t.Fatalf("ss.WriteKeyValues(...): unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"environment":  "unit-test",
+		LabelOwnerKind: "Deployment",
+		LabelOwnerName: "example",
+	}
+	if diff := cmp.Diff(want, applied.Labels); diff != "" {
+		t.Errorf("ss.WriteKeyValues(...): -want labels, +got labels:\n%s", diff)
+	}
+}
+
+func TestControllerOf(t *testing.T) {
+	truth := true
+	ctrl := metav1.OwnerReference{Kind: "Deployment", Name: "example", Controller: &truth}
+	nonCtrl := metav1.OwnerReference{Kind: "ReplicaSet", Name: "other"}
+
+	cases := map[string]struct {
+		reason string
+		refs   []metav1.OwnerReference
+		want   *metav1.OwnerReference
+	}{
+		"NoOwners": {
+			reason: "Should return nil if there are no owner references.",
+			want:   nil,
+		},
+		"NoController": {
+			reason: "Should return nil if no owner reference is a controller.",
+			refs:   []metav1.OwnerReference{nonCtrl},
+			want:   nil,
+		},
+		"HasController": {
+			reason: "Should return the controlling owner reference.",
+			refs:   []metav1.OwnerReference{nonCtrl, ctrl},
+			want:   &ctrl,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := controllerOf(tc.refs)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ncontrollerOf(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// fakeReader is a client.Reader backed by a fixed corev1.Secret and a set of
+// generic owner objects keyed by "Kind/Name", so that CachedEnricher's walk
+// over both typed (ReplicaSet) and unstructured (everything else) owners can
+// be exercised without a real API server.
+type fakeReader struct {
+	secret    *corev1.Secret
+	secretErr error
+	owners    map[string]*unstructured.Unstructured
+	typed     map[string]*appsv1.ReplicaSet
+}
+
+func (f *fakeReader) Get(_ context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		if f.secretErr != nil {
+			return f.secretErr
+		}
+		*o = *f.secret
+		return nil
+	case *appsv1.ReplicaSet:
+		rs, ok := f.typed[key.Name]
+		if !ok {
+			return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+		}
+		*o = *rs
+		return nil
+	case *unstructured.Unstructured:
+		u, ok := f.owners[o.GetKind()+"/"+key.Name]
+		if !ok {
+			return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+		}
+		*o = *u
+		return nil
+	}
+	return nil
+}
+
+func (f *fakeReader) List(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+	return nil
+}
+
+func unstructuredOwner(kind, name string, owner *metav1.OwnerReference, labels map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetKind(kind)
+	u.SetName(name)
+	u.SetLabels(labels)
+	if owner != nil {
+		u.SetOwnerReferences([]metav1.OwnerReference{*owner})
+	}
+	return u
+}
+
+func controllerRef(kind, name string) metav1.OwnerReference {
+	truth := true
+	return metav1.OwnerReference{Kind: kind, Name: name, Controller: &truth}
+}
+
+func controllerRefPtr(kind, name string) *metav1.OwnerReference {
+	ref := controllerRef(kind, name)
+	return &ref
+}
+
+func TestCachedEnricherEnrich(t *testing.T) {
+	type want struct {
+		labels map[string]string
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		reader client.Reader
+		want   want
+	}{
+		"SecretNotFound": {
+			reason: "Should return no labels and no error for a secret that doesn't exist yet.",
+			reader: &fakeReader{secretErr: kerrors.NewNotFound(schema.GroupResource{}, fakeSecretName)},
+			want:   want{},
+		},
+		"SecretHasNoOwner": {
+			reason: "Should return no labels and no error for a secret with no controlling owner.",
+			reader: &fakeReader{secret: &corev1.Secret{}},
+			want:   want{},
+		},
+		"HardcodedReplicaSetIndirection": {
+			reason: "Should resolve a ReplicaSet's owning Deployment via the hardcoded indirection.",
+			reader: &fakeReader{
+				secret: &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						OwnerReferences: []metav1.OwnerReference{controllerRef("ReplicaSet", "example-rs")},
+					},
+				},
+				typed: map[string]*appsv1.ReplicaSet{
+					"example-rs": {
+						ObjectMeta: metav1.ObjectMeta{
+							OwnerReferences: []metav1.OwnerReference{controllerRef("Deployment", "example")},
+						},
+					},
+				},
+				owners: map[string]*unstructured.Unstructured{
+					"Deployment/example": unstructuredOwner("Deployment", "example", nil, nil),
+				},
+			},
+			want: want{
+				labels: map[string]string{
+					LabelOwnerKind: "Deployment",
+					LabelOwnerName: "example",
+				},
+			},
+		},
+		"GenericIndirectionWalksArbitraryOwnerChain": {
+			reason: "Should keep walking an owner chain of kinds it has no hardcoded indirection for, e.g. a managed resource owned by a Composite owned by a Claim.",
+			reader: &fakeReader{
+				secret: &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						OwnerReferences: []metav1.OwnerReference{controllerRef("Composite", "xr-example")},
+					},
+				},
+				owners: map[string]*unstructured.Unstructured{
+					"Composite/xr-example": unstructuredOwner("Composite", "xr-example", controllerRefPtr("Claim", "claim-example"), nil),
+					"Claim/claim-example": unstructuredOwner("Claim", "claim-example", nil, map[string]string{
+						propagateLabelPrefix + "team": "observability",
+					}),
+				},
+			},
+			want: want{
+				labels: map[string]string{
+					LabelOwnerKind: "Claim",
+					LabelOwnerName: "claim-example",
+					"team":         "observability",
+				},
+			},
+		},
+		"CyclicOwnerChainTerminates": {
+			reason: "Should stop walking, rather than loop forever, once an owner reappears in the chain.",
+			reader: &fakeReader{
+				secret: &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						OwnerReferences: []metav1.OwnerReference{controllerRef("Composite", "xr-a")},
+					},
+				},
+				owners: map[string]*unstructured.Unstructured{
+					"Composite/xr-a": unstructuredOwner("Composite", "xr-a", controllerRefPtr("Composite", "xr-b"), nil),
+					"Composite/xr-b": unstructuredOwner("Composite", "xr-b", controllerRefPtr("Composite", "xr-a"), nil),
+				},
+			},
+			want: want{
+				labels: map[string]string{
+					LabelOwnerKind: "Composite",
+					LabelOwnerName: "xr-b",
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &CachedEnricher{reader: tc.reader, indirect: defaultIndirectOwners}
+			got, err := e.Enrich(context.Background(), store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace})
+			if diff := cmp.Diff(tc.want.err, err); diff != "" {
+				t.Errorf("\n%s\ne.Enrich(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.labels, got); diff != "" {
+				t.Errorf("\n%s\ne.Enrich(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package kubernetes implements a connection secret store backed by
+// corev1.Secret resources living in a Kubernetes API server.
+package kubernetes
+
+import (
+	"context"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+const (
+	errGetSecret     = "cannot get secret"
+	errApplySecret   = "cannot apply secret"
+	errUpdateSecret  = "cannot update secret"
+	errDeleteSecret  = "cannot delete secret"
+	errParseMetadata = "cannot parse secret metadata"
+	errEncodePayload = "cannot encode secret payload"
+	errDecodePayload = "cannot decode secret payload"
+	errEnrich        = "cannot enrich secret"
+)
+
+func init() {
+	store.Register(v1.SecretStoreKubernetes, func(ctx context.Context, client resource.ClientApplicator, cfg v1.SecretStoreConfig) (store.SecretStore, error) {
+		return NewSecretStore(ctx, client, cfg)
+	})
+}
+
+// metadata is the shape of a store.Secret's opaque Metadata blob for the
+// Kubernetes store.
+type metadata struct {
+	Labels      map[string]string  `json:"labels,omitempty" cbor:"labels,omitempty"`
+	Annotations map[string]string  `json:"annotations,omitempty" cbor:"annotations,omitempty"`
+	Type        *corev1.SecretType `json:"type,omitempty" cbor:"type,omitempty"`
+}
+
+// SecretStore is a connection SecretStore that stores secrets as
+// corev1.Secret resources in a Kubernetes API server.
+type SecretStore struct {
+	client           resource.ClientApplicator
+	defaultNamespace string
+	encoding         v1.SecretStoreConfigEncoding
+	enricher         store.Enricher
+	revisionRetain   int
+}
+
+// Option configures a SecretStore.
+type Option func(*SecretStore)
+
+// WithEnricher configures the SecretStore to run e before every
+// WriteKeyValues call, merging the labels it returns onto the outgoing
+// secret.
+func WithEnricher(e store.Enricher) Option {
+	return func(ss *SecretStore) {
+		ss.enricher = e
+	}
+}
+
+// WithRevisionRetention configures how many prior revisions UpdateKeyValues
+// keeps when auto-pruning (see store.WithAutoPrune), overriding
+// defaultRevisionRetention. A non-positive retain is ignored.
+func WithRevisionRetention(retain int) Option {
+	return func(ss *SecretStore) {
+		ss.revisionRetain = retain
+	}
+}
+
+// NewSecretStore returns a new SecretStore backed by client, using cfg's
+// DefaultScope as the namespace for secrets that don't specify one of their
+// own, and cfg's Encoding to decide how secret metadata and payloads are
+// represented on the wire.
+func NewSecretStore(_ context.Context, client resource.ClientApplicator, cfg v1.SecretStoreConfig, opts ...Option) (*SecretStore, error) {
+	ss := &SecretStore{
+		client:           client,
+		defaultNamespace: cfg.DefaultScope,
+		encoding:         cfg.Encoding,
+		revisionRetain:   defaultRevisionRetention,
+	}
+	for _, o := range opts {
+		o(ss)
+	}
+	return ss, nil
+}
+
+func (ss *SecretStore) namespaceFor(scope string) string {
+	if scope != "" {
+		return scope
+	}
+	return ss.defaultNamespace
+}
+
+// ReadKeyValues reads all key value pairs of the given secret. If
+// WithReadRevision is supplied, the corresponding revision is read instead
+// of the secret's current value - see UpdateKeyValues.
+func (ss *SecretStore) ReadKeyValues(ctx context.Context, s store.Secret, opts ...store.ReadOption) (store.KeyValues, error) {
+	o := &store.ReadOptions{}
+	for _, f := range opts {
+		f(o)
+	}
+
+	if o.Revision != 0 {
+		return ss.readRevision(ctx, s, o.Revision)
+	}
+
+	secret := &corev1.Secret{}
+	if err := ss.client.Get(ctx, client.ObjectKey{Namespace: ss.namespaceFor(s.Scope), Name: s.Name}, secret); err != nil {
+		return nil, errors.Wrap(err, errGetSecret)
+	}
+
+	if kv, ok, err := decodePayload(secret); ok {
+		if err != nil {
+			return nil, errors.Wrap(err, errDecodePayload)
+		}
+		return kv, nil
+	}
+	return store.KeyValues(secret.Data), nil
+}
+
+// WriteKeyValues writes the supplied key value pairs to the secret,
+// additively patching any existing data and overwriting any existing
+// metadata. If the store was configured with the CBOR encoding, the
+// payload is instead written as a single CBOR-encoded blob.
+func (ss *SecretStore) WriteKeyValues(ctx context.Context, s store.Secret, kv store.KeyValues) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.Name,
+			Namespace: ss.namespaceFor(s.Scope),
+		},
+		Type: resource.SecretTypeConnection,
+		Data: kv,
+	}
+
+	if len(s.Metadata) > 0 {
+		md := &metadata{}
+		if err := unmarshalMetadata(ss.encoding, s.Metadata, md); err != nil {
+			return errors.Wrap(err, errParseMetadata)
+		}
+		secret.Labels = md.Labels
+		secret.Annotations = md.Annotations
+		if md.Type != nil {
+			secret.Type = *md.Type
+		}
+	}
+
+	if ss.encoding == v1.SecretStoreConfigEncodingCBOR {
+		if err := encodePayload(secret, kv); err != nil {
+			return errors.Wrap(err, errEncodePayload)
+		}
+	}
+
+	if ss.enricher != nil {
+		labels, err := ss.enricher.Enrich(ctx, s)
+		if err != nil {
+			return errors.Wrap(err, errEnrich)
+		}
+		if len(labels) > 0 {
+			if secret.Labels == nil {
+				secret.Labels = map[string]string{}
+			}
+			for k, v := range labels {
+				secret.Labels[k] = v
+			}
+		}
+	}
+
+	return errors.Wrap(ss.client.Apply(ctx, secret), errApplySecret)
+}
+
+// DeleteKeyValues deletes key value pairs of the given secret. If no kv
+// supplied, the whole secret is deleted. If kv is supplied, only the
+// matching keys are removed, and the secret itself is only deleted once it
+// has no keys remaining.
+func (ss *SecretStore) DeleteKeyValues(ctx context.Context, s store.Secret, kv store.KeyValues) error {
+	secret := &corev1.Secret{}
+	if err := ss.client.Get(ctx, client.ObjectKey{Namespace: ss.namespaceFor(s.Scope), Name: s.Name}, secret); err != nil {
+		return errors.Wrap(resource.Ignore(kerrors.IsNotFound, err), errGetSecret)
+	}
+
+	if len(kv) == 0 {
+		return errors.Wrap(client.IgnoreNotFound(ss.client.Delete(ctx, secret)), errDeleteSecret)
+	}
+
+	existing, ok, err := decodePayload(secret)
+	if ok {
+		if err != nil {
+			return errors.Wrap(err, errDecodePayload)
+		}
+	} else {
+		existing = store.KeyValues(secret.Data)
+	}
+
+	for k := range kv {
+		delete(existing, k)
+	}
+
+	if len(existing) == 0 {
+		return errors.Wrap(client.IgnoreNotFound(ss.client.Delete(ctx, secret)), errDeleteSecret)
+	}
+
+	if ok {
+		if err := encodePayload(secret, existing); err != nil {
+			return errors.Wrap(err, errEncodePayload)
+		}
+	} else {
+		secret.Data = existing
+	}
+
+	return errors.Wrap(ss.client.Update(ctx, secret), errUpdateSecret)
+}
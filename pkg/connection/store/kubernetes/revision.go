@@ -0,0 +1,221 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	// annotationKeyRevision records the revision number of a secret's
+	// current contents.
+	annotationKeyRevision = "crossplane.io/secret-revision"
+
+	// annotationKeyRevisionFmt is used to record the content hash of a
+	// prior revision, e.g. "crossplane.io/secret-revisions/3".
+	annotationKeyRevisionFmt = "crossplane.io/secret-revisions/%d"
+
+	// annotationKeyDescription records the description supplied to the
+	// most recent UpdateKeyValues call, if any.
+	annotationKeyDescription = "crossplane.io/secret-revision-description"
+
+	// defaultRevisionRetention is how many prior revisions are kept when
+	// auto-pruning is enabled, unless the store is configured with
+	// WithRevisionRetention.
+	defaultRevisionRetention = 5
+)
+
+// revisionSecretName is the name of the Secret that snapshots a prior
+// revision's contents, so that it can still be read back with
+// WithReadRevision after being superseded.
+func revisionSecretName(name string, revision int) string {
+	return fmt.Sprintf("%s-revision-%d", name, revision)
+}
+
+// retention returns how many prior revisions to keep when auto-pruning,
+// defaulting to defaultRevisionRetention for a SecretStore constructed
+// without WithRevisionRetention (including the zero value).
+func (ss *SecretStore) retention() int {
+	if ss.revisionRetain > 0 {
+		return ss.revisionRetain
+	}
+	return defaultRevisionRetention
+}
+
+var _ store.VersionedSecretStore = &SecretStore{}
+
+// UpdateKeyValues updates the given secret's key values, recording its
+// previous contents as a new revision before overwriting them. Revision
+// history is tracked via crossplane.io/secret-revision* annotations on the
+// backing corev1.Secret, with the pre-update contents snapshotted into a
+// sibling revision Secret so they remain readable via WithReadRevision.
+// When auto-pruning is enabled, revisions older than the store's retention
+// window (defaultRevisionRetention, unless overridden with
+// WithRevisionRetention) are deleted as part of the update.
+func (ss *SecretStore) UpdateKeyValues(ctx context.Context, s store.Secret, kv store.KeyValues, opts ...store.UpdateOption) error {
+	o := &store.UpdateOptions{AutoPrune: true}
+	for _, f := range opts {
+		f(o)
+	}
+
+	key := client.ObjectKey{Namespace: ss.namespaceFor(s.Scope), Name: s.Name}
+	current := &corev1.Secret{}
+	exists := true
+	if err := ss.client.Get(ctx, key, current); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return errors.Wrap(err, errGetSecret)
+		}
+		exists = false
+		current = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: s.Name, Namespace: key.Namespace},
+		}
+	}
+
+	// 0 is reserved as the sentinel ReadKeyValues uses to mean "read the
+	// latest revision", so actual revisions are numbered from 1. A Secret
+	// that already existed before it was ever written through this store
+	// has no annotation yet, but its current contents are implicitly
+	// revision 1.
+	revision := 0
+	if v, ok := current.Annotations[annotationKeyRevision]; ok {
+		revision, _ = strconv.Atoi(v)
+	} else if exists {
+		revision = 1
+	}
+	next := revision + 1
+	if o.Revision != 0 {
+		next = o.Revision
+	}
+
+	if current.Annotations == nil {
+		current.Annotations = map[string]string{}
+	}
+
+	if exists {
+		if err := ss.snapshotRevision(ctx, key, revision, current); err != nil {
+			return err
+		}
+		current.Annotations[fmt.Sprintf(annotationKeyRevisionFmt, revision)] = hashKeyValues(current.Data)
+	}
+
+	current.Annotations[annotationKeyRevision] = strconv.Itoa(next)
+	if o.Description != "" {
+		current.Annotations[annotationKeyDescription] = o.Description
+	}
+	current.Data = kv
+
+	if err := ss.client.Apply(ctx, current); err != nil {
+		return errors.Wrap(err, errApplySecret)
+	}
+
+	if o.AutoPrune {
+		return ss.pruneRevisions(ctx, key.Namespace, s.Name, next, ss.retention())
+	}
+	return nil
+}
+
+// snapshotRevision persists a copy of s's current contents so that they
+// remain readable via WithReadRevision(revision) after s is overwritten.
+func (ss *SecretStore) snapshotRevision(ctx context.Context, key client.ObjectKey, revision int, s *corev1.Secret) error {
+	snapshot := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      revisionSecretName(key.Name, revision),
+			Namespace: key.Namespace,
+		},
+		Type: s.Type,
+		Data: s.Data,
+	}
+	return errors.Wrap(ss.client.Apply(ctx, snapshot), errApplySecret)
+}
+
+// readRevision reads back a previously snapshotted revision of s.
+func (ss *SecretStore) readRevision(ctx context.Context, s store.Secret, revision int) (store.KeyValues, error) {
+	snapshot := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: ss.namespaceFor(s.Scope), Name: revisionSecretName(s.Name, revision)}
+	if err := ss.client.Get(ctx, key, snapshot); err != nil {
+		return nil, errors.Wrap(err, errGetSecret)
+	}
+	return store.KeyValues(snapshot.Data), nil
+}
+
+// pruneRevisions deletes revision snapshots and their annotations once more
+// than retain of them exist, keeping the most recent ones.
+func (ss *SecretStore) pruneRevisions(ctx context.Context, namespace, name string, latest, retain int) error {
+	oldest := latest - retain
+	if oldest < 1 {
+		return nil
+	}
+
+	current := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: namespace, Name: name}
+	if err := ss.client.Get(ctx, key, current); err != nil {
+		return errors.Wrap(err, errGetSecret)
+	}
+
+	stale := []int{}
+	for k := range current.Annotations {
+		var rev int
+		if _, err := fmt.Sscanf(k, annotationKeyRevisionFmt, &rev); err != nil {
+			continue
+		}
+		if rev < oldest {
+			stale = append(stale, rev)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	sort.Ints(stale)
+	for _, rev := range stale {
+		delete(current.Annotations, fmt.Sprintf(annotationKeyRevisionFmt, rev))
+		snapshot := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: revisionSecretName(name, rev), Namespace: namespace}}
+		if err := client.IgnoreNotFound(ss.client.Delete(ctx, snapshot)); err != nil {
+			return errors.Wrap(err, errDeleteSecret)
+		}
+	}
+
+	return errors.Wrap(ss.client.Update(ctx, current), errUpdateSecret)
+}
+
+func hashKeyValues(kv map[string][]byte) string {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write(kv[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,362 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestSecretStoreUpdateKeyValues(t *testing.T) {
+	type args struct {
+		client resource.ClientApplicator
+		secret store.Secret
+		kv     store.KeyValues
+		opts   []store.UpdateOption
+	}
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"CannotGetSecret": {
+			reason: "Should return a proper error if it cannot get the existing secret.",
+			args: args{
+				client: resource.ClientApplicator{
+					Client: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+				},
+				secret: store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace},
+			},
+			want: want{err: errors.Wrap(errBoom, errGetSecret)},
+		},
+		"FirstRevisionIsCreated": {
+			reason: "Should stamp revision 1 and skip snapshotting when the secret doesn't exist yet.",
+			args: args{
+				client: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, fakeSecretName)),
+					},
+					Applicator: resource.ApplyFn(func(ctx context.Context, obj client.Object, option ...resource.ApplyOption) error {
+						got := obj.(*corev1.Secret)
+						if diff := cmp.Diff("1", got.Annotations[annotationKeyRevision]); diff != "" {
+							t.Errorf("r: -want, +got:\n%s", diff)
+						}
+						return nil
+					}),
+				},
+				secret: store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace},
+				kv:     fakeKV,
+			},
+			want: want{err: nil},
+		},
+		"SubsequentRevisionSnapshotsPrevious": {
+			reason: "Should snapshot the previous contents and bump the revision annotation.",
+			args: args{
+				client: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+							*obj.(*corev1.Secret) = *fakeConnectionSecret(
+								withData(fakeKV),
+								withAnnotations(map[string]string{annotationKeyRevision: "1"}),
+							)
+							return nil
+						}),
+					},
+					Applicator: resource.ApplyFn(func(ctx context.Context, obj client.Object, option ...resource.ApplyOption) error {
+						got := obj.(*corev1.Secret)
+						switch got.Name {
+						case revisionSecretName(fakeSecretName, 1):
+							if diff := cmp.Diff(store.KeyValues(fakeKV), store.KeyValues(got.Data)); diff != "" {
+								t.Errorf("snapshot: -want, +got:\n%s", diff)
+							}
+						case fakeSecretName:
+							if diff := cmp.Diff("2", got.Annotations[annotationKeyRevision]); diff != "" {
+								t.Errorf("revision: -want, +got:\n%s", diff)
+							}
+						default:
+							t.Errorf("unexpected apply of secret %q", got.Name)
+						}
+						return nil
+					}),
+				},
+				secret: store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace},
+				kv:     store.KeyValues(map[string][]byte{"key1": []byte("new-value")}),
+			},
+			want: want{err: nil},
+		},
+		"PreExistingSecretSnapshotsAsRevisionOne": {
+			reason: "Should snapshot a Secret that predates revision tracking as revision 1, not revision 0, so it remains readable via WithReadRevision.",
+			args: args{
+				client: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+							*obj.(*corev1.Secret) = *fakeConnectionSecret(withData(fakeKV))
+							return nil
+						}),
+					},
+					Applicator: resource.ApplyFn(func(ctx context.Context, obj client.Object, option ...resource.ApplyOption) error {
+						got := obj.(*corev1.Secret)
+						switch got.Name {
+						case revisionSecretName(fakeSecretName, 1):
+							if diff := cmp.Diff(store.KeyValues(fakeKV), store.KeyValues(got.Data)); diff != "" {
+								t.Errorf("snapshot: -want, +got:\n%s", diff)
+							}
+						case fakeSecretName:
+							if diff := cmp.Diff("2", got.Annotations[annotationKeyRevision]); diff != "" {
+								t.Errorf("revision: -want, +got:\n%s", diff)
+							}
+						default:
+							t.Errorf("unexpected apply of secret %q", got.Name)
+						}
+						return nil
+					}),
+				},
+				secret: store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace},
+				kv:     store.KeyValues(map[string][]byte{"key1": []byte("new-value")}),
+			},
+			want: want{err: nil},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss := &SecretStore{client: tc.args.client}
+			err := ss.UpdateKeyValues(context.Background(), tc.args.secret, tc.args.kv, tc.args.opts...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.UpdateKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretStoreReadKeyValuesAtRevision(t *testing.T) {
+	type args struct {
+		client resource.ClientApplicator
+		secret store.Secret
+		opts   []store.ReadOption
+	}
+	type want struct {
+		result store.KeyValues
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"CannotGetRevision": {
+			reason: "Should return a proper error if the revision snapshot cannot be retrieved.",
+			args: args{
+				client: resource.ClientApplicator{
+					Client: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+				},
+				secret: store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace},
+				opts:   []store.ReadOption{store.WithReadRevision(1)},
+			},
+			want: want{err: errors.Wrap(errBoom, errGetSecret)},
+		},
+		"RevisionIsReadBack": {
+			reason: "Should read the snapshot Secret for the requested revision rather than the current Secret.",
+			args: args{
+				client: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+							got := obj.(*corev1.Secret)
+							if diff := cmp.Diff(revisionSecretName(fakeSecretName, 1), got.Name); diff != "" {
+								t.Errorf("r: -want secret name, +got:\n%s", diff)
+							}
+							*got = *fakeConnectionSecret(withData(fakeKV))
+							got.Name = revisionSecretName(fakeSecretName, 1)
+							return nil
+						}),
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName, Scope: fakeSecretNamespace},
+				opts:   []store.ReadOption{store.WithReadRevision(1)},
+			},
+			want: want{result: store.KeyValues(fakeKV)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss := &SecretStore{client: tc.args.client}
+			got, err := ss.ReadKeyValues(context.Background(), tc.args.secret, tc.args.opts...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.ReadKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nss.ReadKeyValues(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretStorePruneRevisions(t *testing.T) {
+	type args struct {
+		client    resource.ClientApplicator
+		namespace string
+		name      string
+		latest    int
+		retain    int
+	}
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"NothingToPruneYet": {
+			reason: "Should do nothing if fewer revisions exist than the retention window.",
+			args: args{
+				name:   fakeSecretName,
+				latest: 3,
+				retain: defaultRevisionRetention,
+			},
+			want: want{err: nil},
+		},
+		"CannotGetSecret": {
+			reason: "Should return a proper error if the current secret cannot be retrieved.",
+			args: args{
+				client: resource.ClientApplicator{
+					Client: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+				},
+				name:   fakeSecretName,
+				latest: 10,
+				retain: 5,
+			},
+			want: want{err: errors.Wrap(errBoom, errGetSecret)},
+		},
+		"StaleRevisionsDeleted": {
+			reason: "Should delete snapshots and annotations for revisions older than the retention window, keeping the rest.",
+			args: args{
+				client: resource.ClientApplicator{
+					Client: &test.MockClient{
+						MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+							*obj.(*corev1.Secret) = *fakeConnectionSecret(withAnnotations(map[string]string{
+								annotationKeyRevision:                    "11",
+								fmt.Sprintf(annotationKeyRevisionFmt, 4): hashKeyValues(fakeKV),
+								fmt.Sprintf(annotationKeyRevisionFmt, 5): hashKeyValues(fakeKV),
+								fmt.Sprintf(annotationKeyRevisionFmt, 6): hashKeyValues(fakeKV),
+							}))
+							return nil
+						}),
+						MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+							deleted = append(deleted, obj.(*corev1.Secret).Name)
+							return nil
+						},
+						MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+							got := obj.(*corev1.Secret)
+							if _, ok := got.Annotations[fmt.Sprintf(annotationKeyRevisionFmt, 4)]; ok {
+								t.Errorf("expected stale revision 4's annotation to be removed")
+							}
+							if _, ok := got.Annotations[fmt.Sprintf(annotationKeyRevisionFmt, 6)]; !ok {
+								t.Errorf("expected revision 6's annotation to be kept")
+							}
+							return nil
+						},
+					},
+				},
+				namespace: fakeSecretNamespace,
+				name:      fakeSecretName,
+				latest:    11,
+				retain:    5,
+			},
+			want: want{err: nil},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			deleted = nil
+			ss := &SecretStore{client: tc.args.client}
+			err := ss.pruneRevisions(context.Background(), tc.args.namespace, tc.args.name, tc.args.latest, tc.args.retain)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.pruneRevisions(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if name == "StaleRevisionsDeleted" {
+				want := []string{revisionSecretName(fakeSecretName, 4), revisionSecretName(fakeSecretName, 5)}
+				sort.Strings(deleted)
+				sort.Strings(want)
+				if diff := cmp.Diff(want, deleted); diff != "" {
+					t.Errorf("\n%s\nss.pruneRevisions(...): -want deleted, +got deleted:\n%s", tc.reason, diff)
+				}
+			}
+		})
+	}
+}
+
+var deleted []string
+
+// TestSecretStoreRetention proves that WithRevisionRetention overrides
+// defaultRevisionRetention, and that a non-positive retain (including the
+// zero value of a SecretStore built without it) falls back to the default.
+func TestSecretStoreRetention(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		opts   []Option
+		want   int
+	}{
+		"DefaultsWhenUnset": {
+			reason: "Should fall back to defaultRevisionRetention when WithRevisionRetention isn't supplied.",
+			want:   defaultRevisionRetention,
+		},
+		"OverriddenByOption": {
+			reason: "Should use the configured retention window.",
+			opts:   []Option{WithRevisionRetention(10)},
+			want:   10,
+		},
+		"NonPositiveIgnored": {
+			reason: "Should ignore a non-positive retain and fall back to the default.",
+			opts:   []Option{WithRevisionRetention(0)},
+			want:   defaultRevisionRetention,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss, err := NewSecretStore(context.Background(), resource.ClientApplicator{}, v1.SecretStoreConfig{}, tc.opts...)
+			if err != nil {
+				t.Fatalf("NewSecretStore(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, ss.retention()); diff != "" {
+				t.Errorf("\n%s\nss.retention(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
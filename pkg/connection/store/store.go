@@ -0,0 +1,187 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package store contains the interfaces and the registry that connection
+// secret stores implement and register themselves against.
+package store
+
+import (
+	"context"
+	"sync"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+const (
+	errNoSuchStore   = "no secret store is registered for type %q"
+	errStoreTypeNil  = "secret store config is missing a type"
+	errFactoryFailed = "cannot build secret store"
+)
+
+// KeyValues is a set of key-value pairs that make up the body of a
+// connection secret.
+type KeyValues map[string][]byte
+
+// Secret is a connection secret instance that will be written to, read from
+// or deleted from a SecretStore.
+type Secret struct {
+	// Name of the secret.
+	Name string
+
+	// Scope of the secret, e.g. its namespace in the Kubernetes store, or the
+	// equivalent "path" prefix in any other store.
+	Scope string
+
+	// Metadata is an opaque, store-specific blob (typically JSON) describing
+	// labels, annotations and other metadata that should be associated with
+	// the secret.
+	Metadata []byte
+}
+
+// SecretStore is the interface implemented by every connection secret
+// backend, e.g. Kubernetes Secrets, Vault, or AWS Secrets Manager.
+type SecretStore interface {
+	ReadKeyValues(ctx context.Context, s Secret, opts ...ReadOption) (KeyValues, error)
+	WriteKeyValues(ctx context.Context, s Secret, kv KeyValues) error
+	DeleteKeyValues(ctx context.Context, s Secret, kv KeyValues) error
+}
+
+// VersionedSecretStore is a SecretStore that also supports explicit,
+// revisioned updates - e.g. for user-facing secret rotation, where
+// consumers may want visibility into prior values rather than having them
+// silently overwritten.
+type VersionedSecretStore interface {
+	SecretStore
+
+	// UpdateKeyValues updates the given secret's key values, recording the
+	// previous contents as a new revision.
+	UpdateKeyValues(ctx context.Context, s Secret, kv KeyValues, opts ...UpdateOption) error
+}
+
+// ReadOptions are the configurable options of a ReadKeyValues call. Stores
+// that don't support a particular option are free to ignore it.
+type ReadOptions struct {
+	// Revision, when non-zero, asks the store to return a specific past
+	// revision of the secret rather than its latest value.
+	Revision int
+}
+
+// A ReadOption configures a ReadOptions.
+type ReadOption func(*ReadOptions)
+
+// WithReadRevision asks ReadKeyValues to return the given past revision of
+// a secret, rather than its latest value. Only meaningful for stores that
+// retain revision history, e.g. via VersionedSecretStore.UpdateKeyValues.
+func WithReadRevision(revision int) ReadOption {
+	return func(o *ReadOptions) {
+		o.Revision = revision
+	}
+}
+
+// UpdateOptions are the configurable options of an UpdateKeyValues call.
+type UpdateOptions struct {
+	// AutoPrune removes revisions beyond the store's retention window as
+	// part of the update. When false, old revisions are kept indefinitely
+	// and may be retrieved with WithReadRevision.
+	AutoPrune bool
+
+	// Revision pins the update to a specific revision number, rather than
+	// letting the store pick the next one. Mostly useful for replaying or
+	// retrying a previously attempted update.
+	Revision int
+
+	// Description is stored alongside the update, e.g. a human readable
+	// reason for the rotation.
+	Description string
+}
+
+// An UpdateOption configures an UpdateOptions.
+type UpdateOption func(*UpdateOptions)
+
+// WithAutoPrune enables or disables automatic pruning of old revisions
+// during an UpdateKeyValues call.
+func WithAutoPrune(prune bool) UpdateOption {
+	return func(o *UpdateOptions) {
+		o.AutoPrune = prune
+	}
+}
+
+// WithRevision pins an UpdateKeyValues call to a specific revision number.
+func WithRevision(revision int) UpdateOption {
+	return func(o *UpdateOptions) {
+		o.Revision = revision
+	}
+}
+
+// WithDescription attaches a human readable description to an
+// UpdateKeyValues call.
+func WithDescription(description string) UpdateOption {
+	return func(o *UpdateOptions) {
+		o.Description = description
+	}
+}
+
+// Enricher runs before WriteKeyValues, returning labels that should be
+// merged onto the outgoing secret in addition to whatever Secret.Metadata
+// already specifies. Implementations typically resolve context that goes
+// beyond what's encoded in Metadata, e.g. a managed resource's owner chain.
+type Enricher interface {
+	Enrich(ctx context.Context, s Secret) (map[string]string, error)
+}
+
+// Factory builds a new SecretStore for the given config. It is implemented
+// by each backend package and registered against a v1.SecretStoreType with
+// Register so that New can dispatch to it.
+type Factory func(ctx context.Context, client resource.ClientApplicator, cfg v1.SecretStoreConfig) (SecretStore, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[v1.SecretStoreType]Factory{}
+)
+
+// Register associates a Factory with a v1.SecretStoreType. Backends are
+// expected to call Register from an init function so that New can build
+// them on demand. Registering the same type twice overwrites the previous
+// Factory, which allows out-of-tree plugins to override a built-in backend
+// if they need to.
+func Register(t v1.SecretStoreType, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[t] = f
+}
+
+// New builds the SecretStore registered for cfg's type. It returns an error
+// if cfg does not specify a type, or if no Factory is registered for it.
+func New(ctx context.Context, client resource.ClientApplicator, cfg v1.SecretStoreConfig) (SecretStore, error) {
+	if cfg.Type == nil {
+		return nil, errors.New(errStoreTypeNil)
+	}
+
+	mu.RLock()
+	f, ok := factories[*cfg.Type]
+	mu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf(errNoSuchStore, *cfg.Type)
+	}
+
+	ss, err := f(ctx, client, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errFactoryFailed)
+	}
+	return ss, nil
+}
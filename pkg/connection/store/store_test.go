@@ -0,0 +1,79 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package store
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+type mockStore struct{ SecretStore }
+
+var storeTypeFake = v1.SecretStoreType("Fake")
+
+func TestNew(t *testing.T) {
+	type args struct {
+		cfg v1.SecretStoreConfig
+	}
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"NoType": {
+			reason: "Should return an error if the config has no type.",
+			args:   args{cfg: v1.SecretStoreConfig{}},
+			want:   want{err: errors.New(errStoreTypeNil)},
+		},
+		"NoSuchStore": {
+			reason: "Should return an error if no Factory is registered for the type.",
+			args: args{cfg: v1.SecretStoreConfig{
+				Type: func() *v1.SecretStoreType { t := v1.SecretStoreType("DoesNotExist"); return &t }(),
+			}},
+			want: want{err: errors.Errorf(errNoSuchStore, v1.SecretStoreType("DoesNotExist"))},
+		},
+		"Registered": {
+			reason: "Should build the store using the Factory registered for the type.",
+			args:   args{cfg: v1.SecretStoreConfig{Type: &storeTypeFake}},
+			want:   want{err: nil},
+		},
+	}
+
+	Register(storeTypeFake, func(_ context.Context, _ resource.ClientApplicator, _ v1.SecretStoreConfig) (SecretStore, error) {
+		return &mockStore{}, nil
+	})
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(context.Background(), resource.ClientApplicator{}, tc.args.cfg)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nNew(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package vault implements a connection secret store backed by the KV v2
+// secrets engine of a HashiCorp Vault server.
+package vault
+
+import (
+	"context"
+	"encoding/json"
+
+	vaultapi "github.com/hashicorp/vault/api"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+const (
+	errNoConfig       = "vault secret store config is missing"
+	errNewClient      = "cannot create vault client"
+	errLogin          = "cannot authenticate to vault"
+	errRead           = "cannot read secret from vault"
+	errWrite          = "cannot write secret to vault"
+	errDelete         = "cannot delete secret from vault"
+	errParseMetadata  = "cannot parse secret metadata"
+	errUnknownAuth    = "unsupported vault auth method"
+	dataKey           = "data"
+	customMetadataKey = "custom_metadata"
+)
+
+func init() {
+	store.Register(v1.SecretStoreVault, func(ctx context.Context, _ resource.ClientApplicator, cfg v1.SecretStoreConfig) (store.SecretStore, error) {
+		return NewSecretStore(ctx, cfg)
+	})
+}
+
+// metadata is the shape of a store.Secret's opaque Metadata blob for the
+// Vault store.
+type metadata struct {
+	CustomMetadata map[string]string `json:"labels,omitempty"`
+}
+
+// logical is the subset of the Vault API client used by SecretStore. It
+// exists so that it can be faked in tests without standing up a Vault
+// server.
+type logical interface {
+	ReadWithContext(ctx context.Context, path string) (*vaultapi.Secret, error)
+	WriteWithContext(ctx context.Context, path string, data map[string]interface{}) (*vaultapi.Secret, error)
+	DeleteWithContext(ctx context.Context, path string) (*vaultapi.Secret, error)
+}
+
+// SecretStore is a connection SecretStore that stores secrets in the KV v2
+// engine of a HashiCorp Vault server.
+type SecretStore struct {
+	client logical
+
+	// mountPath is the KV v2 mount, e.g. "secret".
+	mountPath string
+}
+
+// NewSecretStore returns a new SecretStore that talks to the Vault server
+// described by cfg.Vault, authenticating with either AppRole or Kubernetes
+// auth as configured.
+func NewSecretStore(ctx context.Context, cfg v1.SecretStoreConfig) (*SecretStore, error) {
+	if cfg.Vault == nil {
+		return nil, errors.New(errNoConfig)
+	}
+
+	c, err := vaultapi.NewClient(&vaultapi.Config{Address: cfg.Vault.Server})
+	if err != nil {
+		return nil, errors.Wrap(err, errNewClient)
+	}
+
+	if cfg.Vault.CABundle != "" {
+		if err := c.SetCACert(cfg.Vault.CABundle); err != nil {
+			return nil, errors.Wrap(err, errNewClient)
+		}
+	}
+
+	token, err := login(ctx, c, cfg.Vault.Auth)
+	if err != nil {
+		return nil, errors.Wrap(err, errLogin)
+	}
+	c.SetToken(token)
+
+	return &SecretStore{
+		client:    c.Logical(),
+		mountPath: cfg.Vault.MountPath,
+	}, nil
+}
+
+// login authenticates against Vault using the configured auth method and
+// returns a client token. AppRole and Kubernetes auth are supported, since
+// those are the two methods most commonly used by workloads running inside
+// a cluster.
+func login(ctx context.Context, c *vaultapi.Client, auth *v1.VaultAuth) (string, error) {
+	switch {
+	case auth == nil:
+		return "", errors.New(errUnknownAuth)
+	case auth.AppRole != nil:
+		secret, err := c.Logical().WriteWithContext(ctx, auth.AppRole.Path, map[string]interface{}{
+			"role_id":   auth.AppRole.RoleID,
+			"secret_id": auth.AppRole.SecretID,
+		})
+		if err != nil {
+			return "", err
+		}
+		return secret.Auth.ClientToken, nil
+	case auth.Kubernetes != nil:
+		secret, err := c.Logical().WriteWithContext(ctx, auth.Kubernetes.Path, map[string]interface{}{
+			"role": auth.Kubernetes.Role,
+			"jwt":  auth.Kubernetes.JWT,
+		})
+		if err != nil {
+			return "", err
+		}
+		return secret.Auth.ClientToken, nil
+	default:
+		return "", errors.New(errUnknownAuth)
+	}
+}
+
+func (ss *SecretStore) path(s store.Secret) string {
+	scope := s.Scope
+	if scope != "" {
+		return ss.mountPath + "/data/" + scope + "/" + s.Name
+	}
+	return ss.mountPath + "/data/" + s.Name
+}
+
+// metadataPath returns the path of the KV v2 metadata endpoint for s, which
+// is where Vault expects custom_metadata to be written.
+func (ss *SecretStore) metadataPath(s store.Secret) string {
+	scope := s.Scope
+	if scope != "" {
+		return ss.mountPath + "/metadata/" + scope + "/" + s.Name
+	}
+	return ss.mountPath + "/metadata/" + s.Name
+}
+
+// ReadKeyValues reads all key value pairs of the latest version of the given
+// secret. This store does not read older KV v2 versions, so any ReadOption
+// is ignored.
+func (ss *SecretStore) ReadKeyValues(ctx context.Context, s store.Secret, _ ...store.ReadOption) (store.KeyValues, error) {
+	secret, err := ss.client.ReadWithContext(ctx, ss.path(s))
+	if err != nil {
+		return nil, errors.Wrap(err, errRead)
+	}
+	if secret == nil {
+		return store.KeyValues{}, nil
+	}
+
+	data, _ := secret.Data[dataKey].(map[string]interface{})
+	kv := make(store.KeyValues, len(data))
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			kv[k] = []byte(s)
+		}
+	}
+	return kv, nil
+}
+
+// WriteKeyValues additively patches the secret with the supplied key value
+// pairs - any existing keys not present in kv are preserved - and stamps
+// custom_metadata derived from the secret's Metadata.
+func (ss *SecretStore) WriteKeyValues(ctx context.Context, s store.Secret, kv store.KeyValues) error {
+	existing, err := ss.ReadKeyValues(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	merged := make(store.KeyValues, len(existing)+len(kv))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range kv {
+		merged[k] = v
+	}
+
+	return ss.write(ctx, s, merged)
+}
+
+// write overwrites the secret's data with exactly kv, stamping
+// custom_metadata derived from the secret's Metadata. Callers that need an
+// additive patch should merge kv with the secret's existing data first -
+// see WriteKeyValues.
+func (ss *SecretStore) write(ctx context.Context, s store.Secret, kv store.KeyValues) error {
+	data := make(map[string]interface{}, len(kv))
+	for k, v := range kv {
+		data[k] = string(v)
+	}
+
+	if _, err := ss.client.WriteWithContext(ctx, ss.path(s), map[string]interface{}{dataKey: data}); err != nil {
+		return errors.Wrap(err, errWrite)
+	}
+
+	if len(s.Metadata) > 0 {
+		md := &metadata{}
+		if err := json.Unmarshal(s.Metadata, md); err != nil {
+			return errors.Wrap(err, errParseMetadata)
+		}
+		if len(md.CustomMetadata) > 0 {
+			if _, err := ss.client.WriteWithContext(ctx, ss.metadataPath(s), map[string]interface{}{customMetadataKey: md.CustomMetadata}); err != nil {
+				return errors.Wrap(err, errWrite)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeleteKeyValues deletes key value pairs of the given secret. Vault's KV v2
+// engine only supports deleting a whole version, so if kv is supplied the
+// remaining keys are written back as a new version. Otherwise the secret is
+// deleted via its metadata endpoint, which destroys every version and its
+// custom_metadata - a DeleteWithContext on the data endpoint would only
+// soft-delete the latest version, leaving history and metadata behind.
+func (ss *SecretStore) DeleteKeyValues(ctx context.Context, s store.Secret, kv store.KeyValues) error {
+	if len(kv) == 0 {
+		if _, err := ss.client.DeleteWithContext(ctx, ss.metadataPath(s)); err != nil {
+			return errors.Wrap(err, errDelete)
+		}
+		return nil
+	}
+
+	existing, err := ss.ReadKeyValues(ctx, s)
+	if err != nil {
+		return err
+	}
+	for k := range kv {
+		delete(existing, k)
+	}
+
+	if len(existing) == 0 {
+		if _, err := ss.client.DeleteWithContext(ctx, ss.metadataPath(s)); err != nil {
+			return errors.Wrap(err, errDelete)
+		}
+		return nil
+	}
+
+	return ss.write(ctx, s, existing)
+}
@@ -0,0 +1,379 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package vault
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	vaultapi "github.com/hashicorp/vault/api"
+
+	"github.com/crossplane/crossplane-runtime/pkg/connection/store"
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var (
+	errBoom = errors.New("boom")
+
+	fakeSecretName      = "fake"
+	fakeSecretNamespace = "fake-namespace"
+
+	fakeKV = map[string][]byte{
+		"key1": []byte("value1"),
+		"key2": []byte("value2"),
+		"key3": []byte("value3"),
+	}
+)
+
+type fakeLogical struct {
+	MockReadWithContext   func(ctx context.Context, path string) (*vaultapi.Secret, error)
+	MockWriteWithContext  func(ctx context.Context, path string, data map[string]interface{}) (*vaultapi.Secret, error)
+	MockDeleteWithContext func(ctx context.Context, path string) (*vaultapi.Secret, error)
+}
+
+func (f *fakeLogical) ReadWithContext(ctx context.Context, path string) (*vaultapi.Secret, error) {
+	return f.MockReadWithContext(ctx, path)
+}
+
+func (f *fakeLogical) WriteWithContext(ctx context.Context, path string, data map[string]interface{}) (*vaultapi.Secret, error) {
+	return f.MockWriteWithContext(ctx, path, data)
+}
+
+func (f *fakeLogical) DeleteWithContext(ctx context.Context, path string) (*vaultapi.Secret, error) {
+	return f.MockDeleteWithContext(ctx, path)
+}
+
+func fakeVaultSecret(kv map[string][]byte) *vaultapi.Secret {
+	data := make(map[string]interface{}, len(kv))
+	for k, v := range kv {
+		data[k] = string(v)
+	}
+	return &vaultapi.Secret{Data: map[string]interface{}{dataKey: data}}
+}
+
+func TestSecretStoreReadKeyValues(t *testing.T) {
+	type args struct {
+		client logical
+		secret store.Secret
+	}
+	type want struct {
+		result store.KeyValues
+		err    error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"CannotReadSecret": {
+			reason: "Should return a proper error if cannot read the secret",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return nil, errBoom
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errRead),
+			},
+		},
+		"NoSuchSecret": {
+			reason: "Should return no key values if the secret doesn't exist.",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return nil, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+			},
+			want: want{
+				result: store.KeyValues{},
+			},
+		},
+		"SuccessfulRead": {
+			reason: "Should return all key values after a successful read",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return fakeVaultSecret(fakeKV), nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+			},
+			want: want{
+				result: store.KeyValues(fakeKV),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss := &SecretStore{client: tc.args.client}
+			got, err := ss.ReadKeyValues(context.Background(), tc.args.secret)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.ReadKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nss.ReadKeyValues(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretStoreWriteKeyValues(t *testing.T) {
+	type args struct {
+		client logical
+		secret store.Secret
+		kv     store.KeyValues
+	}
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"CannotReadExistingSecret": {
+			reason: "Should return a proper error if the existing secret cannot be read.",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return nil, errBoom
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv:     store.KeyValues(fakeKV),
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errRead),
+			},
+		},
+		"WriteFailed": {
+			reason: "Should return a proper error when the write fails.",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return nil, nil
+					},
+					MockWriteWithContext: func(_ context.Context, _ string, _ map[string]interface{}) (*vaultapi.Secret, error) {
+						return nil, errBoom
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv:     store.KeyValues(fakeKV),
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errWrite),
+			},
+		},
+		"SecretPatchedAdditively": {
+			reason: "Should merge new key values into any existing data rather than overwriting it.",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return fakeVaultSecret(map[string][]byte{"existing-key": []byte("existing-value")}), nil
+					},
+					MockWriteWithContext: func(_ context.Context, _ string, data map[string]interface{}) (*vaultapi.Secret, error) {
+						want := map[string]interface{}{
+							dataKey: map[string]interface{}{
+								"existing-key": "existing-value",
+								"new-key":      "new-value",
+							},
+						}
+						if diff := cmp.Diff(want, data); diff != "" {
+							t.Errorf("r: -want, +got:\n%s", diff)
+						}
+						return &vaultapi.Secret{}, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv: store.KeyValues(map[string][]byte{
+					"new-key": []byte("new-value"),
+				}),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"SecretMetadataStamped": {
+			reason: "Should write the secret's custom_metadata to the KV v2 metadata endpoint, separately from its data.",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return nil, nil
+					},
+					MockWriteWithContext: func(_ context.Context, path string, data map[string]interface{}) (*vaultapi.Secret, error) {
+						switch path {
+						case "/data/" + fakeSecretName:
+							want := map[string]interface{}{
+								dataKey: map[string]interface{}{"new-key": "new-value"},
+							}
+							if diff := cmp.Diff(want, data); diff != "" {
+								t.Errorf("data write: -want, +got:\n%s", diff)
+							}
+						case "/metadata/" + fakeSecretName:
+							want := map[string]interface{}{
+								customMetadataKey: map[string]string{"owner": "team-a"},
+							}
+							if diff := cmp.Diff(want, data); diff != "" {
+								t.Errorf("metadata write: -want, +got:\n%s", diff)
+							}
+						default:
+							t.Errorf("unexpected write to path %q", path)
+						}
+						return &vaultapi.Secret{}, nil
+					},
+				},
+				secret: store.Secret{
+					Name:     fakeSecretName,
+					Metadata: []byte(`{"labels":{"owner":"team-a"}}`),
+				},
+				kv: store.KeyValues(map[string][]byte{
+					"new-key": []byte("new-value"),
+				}),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss := &SecretStore{client: tc.args.client}
+			err := ss.WriteKeyValues(context.Background(), tc.args.secret, tc.args.kv)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.WriteKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestSecretStoreDeleteKeyValues(t *testing.T) {
+	type args struct {
+		client logical
+		secret store.Secret
+		kv     store.KeyValues
+	}
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"DeleteWholeSecret": {
+			reason: "Should delete the whole secret via its metadata endpoint, destroying every version and its custom_metadata rather than just soft-deleting the latest version.",
+			args: args{
+				client: &fakeLogical{
+					MockDeleteWithContext: func(_ context.Context, path string) (*vaultapi.Secret, error) {
+						if diff := cmp.Diff("/metadata/"+fakeSecretName, path); diff != "" {
+							t.Errorf("path: -want, +got:\n%s", diff)
+						}
+						return nil, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"CannotReadExistingSecret": {
+			reason: "Should return a proper error when it fails to read the secret.",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return nil, errBoom
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv:     store.KeyValues(map[string][]byte{"key1": []byte("value1")}),
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errRead),
+			},
+		},
+		"SecretWrittenBackWithRemainingKeysOnly": {
+			reason: "Should write the remaining keys back without merging in the deleted ones.",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return fakeVaultSecret(fakeKV), nil
+					},
+					MockWriteWithContext: func(_ context.Context, _ string, data map[string]interface{}) (*vaultapi.Secret, error) {
+						want := map[string]interface{}{
+							dataKey: map[string]interface{}{"key3": "value3"},
+						}
+						if diff := cmp.Diff(want, data); diff != "" {
+							t.Errorf("r: -want, +got:\n%s", diff)
+						}
+						return &vaultapi.Secret{}, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv: store.KeyValues(map[string][]byte{
+					"key1": []byte("value1"),
+					"key2": []byte("value2"),
+				}),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"WholeSecretDeletedWhenNoKeysRemain": {
+			reason: "Should delete the whole secret via its metadata endpoint once its last remaining key is removed.",
+			args: args{
+				client: &fakeLogical{
+					MockReadWithContext: func(_ context.Context, _ string) (*vaultapi.Secret, error) {
+						return fakeVaultSecret(map[string][]byte{"key1": []byte("value1")}), nil
+					},
+					MockDeleteWithContext: func(_ context.Context, path string) (*vaultapi.Secret, error) {
+						if diff := cmp.Diff("/metadata/"+fakeSecretName, path); diff != "" {
+							t.Errorf("path: -want, +got:\n%s", diff)
+						}
+						return nil, nil
+					},
+				},
+				secret: store.Secret{Name: fakeSecretName},
+				kv: store.KeyValues(map[string][]byte{
+					"key1": []byte("value1"),
+				}),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ss := &SecretStore{client: tc.args.client}
+			err := ss.DeleteKeyValues(context.Background(), tc.args.secret, tc.args.kv)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nss.DeleteKeyValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errCompileCEL     = "cannot compile CEL rule"
+	errEvalCEL        = "cannot evaluate CEL rule"
+	errCELNotBool     = "CEL rule did not evaluate to a boolean"
+	errToUnstructured = "cannot convert object to an unstructured value for CEL evaluation"
+)
+
+// A CELRule is a single declarative validation rule, expressed as a CEL
+// expression evaluated against the object under validation. Create rules
+// see the object as `self`. Update rules additionally see the prior state
+// of the object as `oldSelf`.
+//
+// `self` and `oldSelf` are dynamically typed (cel.DynType), not a schema
+// derived from the object's Go type, so a mistyped or absent field path
+// isn't rejected at NewValidator time - it surfaces as an internal error
+// the first time the rule is evaluated. Rule authors referencing a field
+// that may not be set should guard it with CEL's has(), e.g.
+// `has(self.spec.forProvider.region) && self.spec.forProvider.region == 'us-east-1'`.
+type CELRule struct {
+	// Rule is the CEL expression that must evaluate to true for the object
+	// to be considered valid, e.g.
+	// `self.spec.forProvider.region in ['us-east-1','us-west-2']`.
+	Rule string
+
+	// Message is returned to the caller when Rule evaluates to false.
+	Message string
+
+	// Reason is an optional, short machine readable reason for the
+	// rejection, analogous to a field.ErrorType.
+	Reason string
+}
+
+// compiledCELRule is a CELRule that has already been type-checked and
+// compiled to a cel.Program, so that evaluating it per request is cheap.
+type compiledCELRule struct {
+	rule CELRule
+	prg  cel.Program
+}
+
+func compileCELRules(rules []CELRule, opts ...cel.EnvOption) ([]compiledCELRule, error) {
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, errCompileCEL)
+	}
+
+	out := make([]compiledCELRule, 0, len(rules))
+	for _, r := range rules {
+		ast, iss := env.Compile(r.Rule)
+		if iss != nil && iss.Err() != nil {
+			return nil, errors.Wrap(iss.Err(), errCompileCEL)
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return nil, errors.Wrap(err, errCompileCEL)
+		}
+		out = append(out, compiledCELRule{rule: r, prg: prg})
+	}
+	return out, nil
+}
+
+// evalCELRules evaluates every rule against vars, aggregating the messages
+// of any that reject the object into a single field.ErrorList-style error,
+// rather than stopping at the first failure. This lets a caller see every
+// violation in one response instead of fixing and resubmitting one at a
+// time.
+func evalCELRules(rules []compiledCELRule, vars map[string]interface{}) error {
+	var errs celErrors
+	for _, r := range rules {
+		out, _, err := r.prg.Eval(vars)
+		if err != nil {
+			return errors.Wrap(err, errEvalCEL)
+		}
+		ok, isBool := out.Value().(bool)
+		if !isBool {
+			return errors.New(errCELNotBool)
+		}
+		if !ok {
+			errs = append(errs, celError{rule: r.rule})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// celError is the failure of a single CELRule.
+type celError struct {
+	rule CELRule
+}
+
+func (e celError) Error() string {
+	msg := e.rule.Message
+	if msg == "" {
+		msg = fmt.Sprintf("failed validation rule: %s", e.rule.Rule)
+	}
+	if e.rule.Reason != "" {
+		return fmt.Sprintf("%s (%s)", msg, e.rule.Reason)
+	}
+	return msg
+}
+
+// celErrors aggregates the celErrors of every CELRule that rejected an
+// object, in field.ErrorList style.
+type celErrors []celError
+
+func (e celErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, ", ")
+}
+
+func toUnstructured(obj runtime.Object) (map[string]interface{}, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, errToUnstructured)
+	}
+	return u, nil
+}
+
+// WithValidateCreateCEL compiles rules once and appends a ValidateCreateFn
+// that evaluates them all against the object being created, aggregating the
+// messages of every rule that fails into a single error. It composes with
+// any ValidateCreateFns set by WithValidateCreateFns.
+func WithValidateCreateCEL(rules ...CELRule) ValidatorOption {
+	return func(v *Validator) {
+		compiled, err := compileCELRules(rules, cel.Variable("self", cel.DynType))
+		if err != nil {
+			v.initErr = err
+			return
+		}
+
+		v.CreationChain = append(v.CreationChain, func(_ context.Context, obj runtime.Object) error {
+			self, err := toUnstructured(obj)
+			if err != nil {
+				return err
+			}
+			return evalCELRules(compiled, map[string]interface{}{"self": self})
+		})
+	}
+}
+
+// WithValidateUpdateCEL compiles rules once and appends a ValidateUpdateFn
+// that evaluates them all against the updated (`self`) and prior
+// (`oldSelf`) object, aggregating the messages of every rule that fails
+// into a single error. It composes with any ValidateUpdateFns set by
+// WithValidateUpdateFns.
+func WithValidateUpdateCEL(rules ...CELRule) ValidatorOption {
+	return func(v *Validator) {
+		compiled, err := compileCELRules(rules, cel.Variable("self", cel.DynType), cel.Variable("oldSelf", cel.DynType))
+		if err != nil {
+			v.initErr = err
+			return
+		}
+
+		v.UpdateChain = append(v.UpdateChain, func(_ context.Context, oldObj, newObj runtime.Object) error {
+			self, err := toUnstructured(newObj)
+			if err != nil {
+				return err
+			}
+			oldSelf, err := toUnstructured(oldObj)
+			if err != nil {
+				return err
+			}
+			return evalCELRules(compiled, map[string]interface{}{"self": self, "oldSelf": oldSelf})
+		})
+	}
+}
+
+// WithImmutableFields compiles a CEL rule per path asserting that the
+// field at that path is unchanged between oldObj and newObj, and appends a
+// ValidateUpdateFn that enforces them. Paths are CEL field selector
+// expressions relative to the object's root, e.g. "spec.forProvider.region".
+func WithImmutableFields(paths ...string) ValidatorOption {
+	rules := make([]CELRule, 0, len(paths))
+	for _, p := range paths {
+		rules = append(rules, CELRule{
+			Rule:    fmt.Sprintf("oldSelf.%s == self.%s", p, p),
+			Message: fmt.Sprintf("%s is immutable", p),
+			Reason:  "FieldIsImmutable",
+		})
+	}
+	return WithValidateUpdateCEL(rules...)
+}
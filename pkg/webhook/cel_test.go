@@ -0,0 +1,132 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCELErrorUsesReason(t *testing.T) {
+	err := celError{rule: CELRule{Message: "spec.region is invalid", Reason: "FieldIsInvalid"}}
+
+	if !strings.Contains(err.Error(), "FieldIsInvalid") {
+		t.Errorf("celError.Error() = %q, want it to contain Reason %q", err.Error(), "FieldIsInvalid")
+	}
+	if !strings.Contains(err.Error(), "spec.region is invalid") {
+		t.Errorf("celError.Error() = %q, want it to contain Message %q", err.Error(), "spec.region is invalid")
+	}
+}
+
+func TestEvalCELRulesAggregatesAllFailures(t *testing.T) {
+	rules := []CELRule{
+		{Rule: "self.a == 'a'", Message: "a is wrong"},
+		{Rule: "self.b == 'b'", Message: "b is wrong"},
+		{Rule: "self.c == 'c'", Message: "c is correct"},
+	}
+
+	compiled, err := compileCELRules(rules, cel.Variable("self", cel.DynType))
+	if err != nil {
+		t.Fatalf("compileCELRules(...): unexpected error: %v", err)
+	}
+
+	err = evalCELRules(compiled, map[string]interface{}{
+		"self": map[string]interface{}{"a": "not-a", "b": "not-b", "c": "c"},
+	})
+	if err == nil {
+		t.Fatal("evalCELRules(...): got nil error, want aggregated failures")
+	}
+
+	if !strings.Contains(err.Error(), "a is wrong") {
+		t.Errorf("evalCELRules(...) error = %q, want it to contain %q", err.Error(), "a is wrong")
+	}
+	if !strings.Contains(err.Error(), "b is wrong") {
+		t.Errorf("evalCELRules(...) error = %q, want it to contain %q", err.Error(), "b is wrong")
+	}
+	if strings.Contains(err.Error(), "c is correct") {
+		t.Errorf("evalCELRules(...) error = %q, want it not to contain %q", err.Error(), "c is correct")
+	}
+}
+
+func TestEvalCELRulesNoFailures(t *testing.T) {
+	compiled, err := compileCELRules([]CELRule{{Rule: "self.a == 'a'", Message: "a is wrong"}}, cel.Variable("self", cel.DynType))
+	if err != nil {
+		t.Fatalf("compileCELRules(...): unexpected error: %v", err)
+	}
+
+	if err := evalCELRules(compiled, map[string]interface{}{"self": map[string]interface{}{"a": "a"}}); err != nil {
+		t.Errorf("evalCELRules(...): unexpected error: %v", err)
+	}
+}
+
+// TestValidateCreateFnsComposeWithCEL proves that WithValidateCreateFns
+// appends to the creation chain rather than replacing it, so CEL rules
+// added by WithValidateCreateCEL survive regardless of option order.
+func TestValidateCreateFnsComposeWithCEL(t *testing.T) {
+	rule := CELRule{Rule: "self.spec.forProvider.region == 'us-east-1'", Message: "region is invalid"}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"forProvider": map[string]interface{}{"region": "us-east-1"}},
+	}}
+
+	for name, v := range map[string]*Validator{
+		"CELFirst": NewValidator(WithValidateCreateCEL(rule), WithValidateCreateFns(passFn)),
+		"FnsFirst": NewValidator(WithValidateCreateFns(passFn), WithValidateCreateCEL(rule)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(2, len(v.CreationChain)); diff != "" {
+				t.Errorf("len(v.CreationChain): -want, +got:\n%s", diff)
+			}
+			if err := v.ValidateCreate(context.Background(), obj); err != nil {
+				t.Errorf("v.ValidateCreate(...): unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func passFn(_ context.Context, _ runtime.Object) error { return nil }
+
+func TestWithImmutableFields(t *testing.T) {
+	v := NewValidator(WithImmutableFields("spec.forProvider.region"))
+
+	old := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"forProvider": map[string]interface{}{"region": "us-east-1"}},
+	}}
+	changed := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"forProvider": map[string]interface{}{"region": "us-west-2"}},
+	}}
+	unchanged := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"forProvider": map[string]interface{}{"region": "us-east-1"}},
+	}}
+
+	if err := v.ValidateUpdate(context.Background(), old, unchanged); err != nil {
+		t.Errorf("v.ValidateUpdate(...): unexpected error for unchanged field: %v", err)
+	}
+
+	err := v.ValidateUpdate(context.Background(), old, changed)
+	if err == nil {
+		t.Fatal("v.ValidateUpdate(...): got nil error, want immutability violation")
+	}
+	if diff := cmp.Diff("spec.forProvider.region is immutable (FieldIsImmutable)", err.Error()); diff != "" {
+		t.Errorf("v.ValidateUpdate(...) error: -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package certrotation
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errGetBundle               = "cannot get CA bundle configmap"
+	errCreateBundle            = "cannot create CA bundle configmap"
+	errUpdateBundle            = "cannot update CA bundle configmap"
+	errGetValidatingWebhook    = "cannot get validating webhook configuration"
+	errUpdateValidatingWebhook = "cannot update validating webhook configuration"
+	errGetMutatingWebhook      = "cannot get mutating webhook configuration"
+	errUpdateMutatingWebhook   = "cannot update mutating webhook configuration"
+
+	keyCABundle = "ca-bundle.crt"
+)
+
+// CABundleConfigMap maintains a ConfigMap containing the PEM-encoded chain
+// of CA certificates that webhook clients should trust, keeping the
+// caBundle field of any configured webhook configurations in sync with it.
+type CABundleConfigMap struct {
+	Client        client.Client
+	Namespace     string
+	ConfigMapName string
+	GracePeriod   time.Duration
+
+	ValidatingWebhookConfigurations []string
+	MutatingWebhookConfigurations   []string
+}
+
+// Append adds ca's certificate to the bundle if it isn't already present,
+// then syncs the bundle to any configured webhook configurations.
+func (b *CABundleConfigMap) Append(ctx context.Context, ca CA) error {
+	cm, err := b.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	certs := splitPEM([]byte(cm.Data[keyCABundle]))
+	if !containsPEM(certs, ca.Cert) {
+		certs = append(certs, ca.Cert)
+		if err := b.save(ctx, cm, certs); err != nil {
+			return err
+		}
+	}
+
+	return b.syncWebhookConfigurations(ctx, joinPEM(certs))
+}
+
+// Prune removes any certificate from the bundle that has expired by more
+// than GracePeriod, then syncs the bundle to any configured webhook
+// configurations.
+func (b *CABundleConfigMap) Prune(ctx context.Context) error {
+	cm, err := b.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	certs := splitPEM([]byte(cm.Data[keyCABundle]))
+	kept := make([][]byte, 0, len(certs))
+	for _, c := range certs {
+		cert, err := parseCertificate(c)
+		if err != nil {
+			// Keep anything we can't parse rather than risk dropping a CA
+			// clients still trust.
+			kept = append(kept, c)
+			continue
+		}
+		if time.Now().After(cert.NotAfter.Add(b.GracePeriod)) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	if len(kept) == len(certs) {
+		return nil
+	}
+	if err := b.save(ctx, cm, kept); err != nil {
+		return err
+	}
+	return b.syncWebhookConfigurations(ctx, joinPEM(kept))
+}
+
+func (b *CABundleConfigMap) get(ctx context.Context) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: b.Namespace, Name: b.ConfigMapName}
+	err := b.Client.Get(ctx, key, cm)
+	switch {
+	case kerrors.IsNotFound(err):
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: b.ConfigMapName, Namespace: b.Namespace}}, nil
+	case err != nil:
+		return nil, errors.Wrap(err, errGetBundle)
+	}
+	return cm, nil
+}
+
+func (b *CABundleConfigMap) save(ctx context.Context, cm *corev1.ConfigMap, certs [][]byte) error {
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[keyCABundle] = string(joinPEM(certs))
+
+	if cm.ResourceVersion == "" {
+		return errors.Wrap(b.Client.Create(ctx, cm), errCreateBundle)
+	}
+	return errors.Wrap(b.Client.Update(ctx, cm), errUpdateBundle)
+}
+
+func (b *CABundleConfigMap) syncWebhookConfigurations(ctx context.Context, bundle []byte) error {
+	for _, name := range b.ValidatingWebhookConfigurations {
+		wc := &admissionv1.ValidatingWebhookConfiguration{}
+		if err := b.Client.Get(ctx, client.ObjectKey{Name: name}, wc); err != nil {
+			return errors.Wrap(err, errGetValidatingWebhook)
+		}
+		for i := range wc.Webhooks {
+			wc.Webhooks[i].ClientConfig.CABundle = bundle
+		}
+		if err := b.Client.Update(ctx, wc); err != nil {
+			return errors.Wrap(err, errUpdateValidatingWebhook)
+		}
+	}
+
+	for _, name := range b.MutatingWebhookConfigurations {
+		wc := &admissionv1.MutatingWebhookConfiguration{}
+		if err := b.Client.Get(ctx, client.ObjectKey{Name: name}, wc); err != nil {
+			return errors.Wrap(err, errGetMutatingWebhook)
+		}
+		for i := range wc.Webhooks {
+			wc.Webhooks[i].ClientConfig.CABundle = bundle
+		}
+		if err := b.Client.Update(ctx, wc); err != nil {
+			return errors.Wrap(err, errUpdateMutatingWebhook)
+		}
+	}
+
+	return nil
+}
+
+func splitPEM(bundle []byte) [][]byte {
+	if len(bundle) == 0 {
+		return nil
+	}
+	parts := bytes.Split(bytes.TrimSpace(bundle), []byte("-----END CERTIFICATE-----"))
+	certs := make([][]byte, 0, len(parts))
+	for _, p := range parts {
+		p = bytes.TrimSpace(p)
+		if len(p) == 0 {
+			continue
+		}
+		certs = append(certs, []byte(strings.TrimSpace(string(p))+"\n-----END CERTIFICATE-----\n"))
+	}
+	return certs
+}
+
+func joinPEM(certs [][]byte) []byte {
+	return bytes.Join(certs, nil)
+}
+
+func containsPEM(certs [][]byte, cert []byte) bool {
+	for _, c := range certs {
+		if bytes.Equal(bytes.TrimSpace(c), bytes.TrimSpace(cert)) {
+			return true
+		}
+	}
+	return false
+}
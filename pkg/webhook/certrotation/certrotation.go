@@ -0,0 +1,186 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package certrotation manages the TLS material used to serve a webhook
+// server in-cluster: a self-signed signing CA, a CA bundle ConfigMap that
+// webhook clients trust, and the serving certificate/key pair handed to
+// controller-runtime's webhook server.
+//
+// Rotation is staged so that clients never stop trusting the server
+// mid-rotation: a new CA is appended to the bundle first, the serving
+// certificate is rotated to it second, and only then are CAs past their
+// grace window pruned from the bundle. Appending and pruning are separate
+// updates of the bundle, so a new CA is never introduced and an old one
+// removed in the same write.
+package certrotation
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+// Options configures Inject.
+type Options struct {
+	// Namespace the signing CA Secret and CA bundle ConfigMap live in.
+	Namespace string
+
+	// SigningSecretName is the name of the Secret holding the current
+	// signing key pair.
+	SigningSecretName string
+
+	// CABundleConfigMapName is the name of the ConfigMap holding the CA
+	// bundle trusted by webhook clients.
+	CABundleConfigMapName string
+
+	// ServingSecretName is the name of the Secret holding the serving
+	// certificate used by controller-runtime's webhook server.
+	ServingSecretName string
+
+	// ServingCertDNSNames are the DNS names the serving certificate is
+	// issued for, e.g. the webhook Service's in-cluster DNS name.
+	ServingCertDNSNames []string
+
+	// ValidatingWebhookConfigurations and MutatingWebhookConfigurations are
+	// the names of the webhook configurations whose caBundle fields should
+	// be kept in sync with CABundleConfigMapName.
+	ValidatingWebhookConfigurations []string
+	MutatingWebhookConfigurations   []string
+
+	// Resync is how often rotation is checked for. Defaults to one hour.
+	Resync time.Duration
+
+	// SigningCertValidity is how long a signing CA is valid for before it
+	// is rotated. Defaults to three years.
+	SigningCertValidity time.Duration
+
+	// ServingCertValidity is how long a serving certificate is valid for
+	// before it is rotated. Defaults to thirty days.
+	ServingCertValidity time.Duration
+
+	// GracePeriod is how long an expired signing CA is kept in the bundle
+	// after a new one has taken over, so that clients that cached the old
+	// bundle still validate the server. Defaults to one day.
+	GracePeriod time.Duration
+
+	// Log is the logger rotation failures are reported to. Defaults to
+	// logging.NewNopLogger().
+	Log logging.Logger
+}
+
+func (o *Options) setDefaults() {
+	if o.Resync == 0 {
+		o.Resync = time.Hour
+	}
+	if o.SigningCertValidity == 0 {
+		o.SigningCertValidity = 3 * 365 * 24 * time.Hour
+	}
+	if o.ServingCertValidity == 0 {
+		o.ServingCertValidity = 30 * 24 * time.Hour
+	}
+	if o.GracePeriod == 0 {
+		o.GracePeriod = 24 * time.Hour
+	}
+	if o.Log == nil {
+		o.Log = logging.NewNopLogger()
+	}
+}
+
+// leaderElectionRunnable wraps a manager.Runnable so that it only runs on
+// the elected leader of an HA deployment. Without this every replica would
+// run the same rotation loop concurrently against the same Secret and
+// ConfigMap, and lose the resulting update conflicts to one another.
+type leaderElectionRunnable struct {
+	manager.Runnable
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (leaderElectionRunnable) NeedLeaderElection() bool {
+	return true
+}
+
+// Inject wires the signing CA, CA bundle and serving cert controllers into
+// mgr as a single Runnable. Each rotation pass runs the stages in order -
+// RotatedSigningCASecret, then CABundleConfigMap's append, then
+// TargetCertKeyPair, then CABundleConfigMap's prune - so that a new CA
+// always reaches the bundle before anything is served with a leaf signed by
+// it, and an old CA is only pruned once nothing being served still depends
+// on it.
+func Inject(mgr manager.Manager, o Options) error {
+	o.setDefaults()
+
+	signer := &RotatedSigningCASecret{
+		Client:     mgr.GetClient(),
+		Namespace:  o.Namespace,
+		SecretName: o.SigningSecretName,
+		Validity:   o.SigningCertValidity,
+	}
+
+	bundle := &CABundleConfigMap{
+		Client:                          mgr.GetClient(),
+		Namespace:                       o.Namespace,
+		ConfigMapName:                   o.CABundleConfigMapName,
+		GracePeriod:                     o.GracePeriod,
+		ValidatingWebhookConfigurations: o.ValidatingWebhookConfigurations,
+		MutatingWebhookConfigurations:   o.MutatingWebhookConfigurations,
+	}
+
+	target := &TargetCertKeyPair{
+		Client:     mgr.GetClient(),
+		Namespace:  o.Namespace,
+		SecretName: o.ServingSecretName,
+		DNSNames:   o.ServingCertDNSNames,
+		Validity:   o.ServingCertValidity,
+	}
+
+	return mgr.Add(leaderElectionRunnable{manager.RunnableFunc(func(ctx context.Context) error {
+		tick := time.NewTicker(o.Resync)
+		defer tick.Stop()
+
+		for {
+			// A failed pass - e.g. an ordinary resourceVersion conflict - is
+			// logged and retried on the next tick rather than returned,
+			// since returning here would abort Manager.Start for the whole
+			// process.
+			if err := rotate(ctx, signer, bundle, target); err != nil {
+				o.Log.Info("Certificate rotation pass failed, will retry next tick", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-tick.C:
+			}
+		}
+	})})
+}
+
+// rotate runs a single, staged rotation pass.
+func rotate(ctx context.Context, signer *RotatedSigningCASecret, bundle *CABundleConfigMap, target *TargetCertKeyPair) error {
+	ca, err := signer.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	if err := bundle.Append(ctx, ca); err != nil {
+		return err
+	}
+	if err := target.Sync(ctx, ca); err != nil {
+		return err
+	}
+	return bundle.Prune(ctx)
+}
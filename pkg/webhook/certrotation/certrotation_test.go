@@ -0,0 +1,160 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package certrotation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var errBoom = errors.New("boom")
+
+// TestLeaderElectionRunnableNeedsLeaderElection proves that Inject's
+// Runnable is gated on leader election, so only one HA replica ever runs
+// the rotation loop.
+func TestLeaderElectionRunnableNeedsLeaderElection(t *testing.T) {
+	r := leaderElectionRunnable{manager.RunnableFunc(func(_ context.Context) error { return nil })}
+	if !r.NeedLeaderElection() {
+		t.Error("leaderElectionRunnable.NeedLeaderElection(): got false, want true")
+	}
+}
+
+func TestSplitJoinContainsPEM(t *testing.T) {
+	ca, err := newSelfSignedCA("test", time.Hour)
+	if err != nil {
+		t.Fatalf("newSelfSignedCA(...): unexpected error: %v", err)
+	}
+	other, err := newSelfSignedCA("other", time.Hour)
+	if err != nil {
+		t.Fatalf("newSelfSignedCA(...): unexpected error: %v", err)
+	}
+
+	bundle := joinPEM([][]byte{ca.Cert, other.Cert})
+	certs := splitPEM(bundle)
+
+	if len(certs) != 2 {
+		t.Fatalf("splitPEM(...): got %d certs, want 2", len(certs))
+	}
+	if !containsPEM(certs, ca.Cert) {
+		t.Error("containsPEM(...): want true for ca.Cert")
+	}
+	if !containsPEM(certs, other.Cert) {
+		t.Error("containsPEM(...): want true for other.Cert")
+	}
+	if diff := cmp.Diff(bundle, joinPEM(certs)); diff != "" {
+		t.Errorf("joinPEM(splitPEM(bundle)): -want, +got:\n%s", diff)
+	}
+}
+
+func TestNewSelfSignedCA(t *testing.T) {
+	ca, err := newSelfSignedCA("test-ca", time.Hour)
+	if err != nil {
+		t.Fatalf("newSelfSignedCA(...): unexpected error: %v", err)
+	}
+
+	cert, err := parseCertificate(ca.Cert)
+	if err != nil {
+		t.Fatalf("parseCertificate(...): unexpected error: %v", err)
+	}
+	if !cert.IsCA {
+		t.Error("parseCertificate(...): got IsCA false, want true")
+	}
+	if cert.Subject.CommonName != "test-ca" {
+		t.Errorf("parseCertificate(...): got CommonName %q, want %q", cert.Subject.CommonName, "test-ca")
+	}
+
+	if _, err := parseECKey(ca.Key); err != nil {
+		t.Errorf("parseECKey(...): unexpected error: %v", err)
+	}
+}
+
+func TestRotatedSigningCASecretSync(t *testing.T) {
+	type args struct {
+		client client.Client
+	}
+	type want struct {
+		err error
+	}
+
+	cases := map[string]struct {
+		reason string
+		args
+		want
+	}{
+		"CannotGetSecret": {
+			reason: "Should return a proper error if the secret cannot be retrieved.",
+			args: args{
+				client: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			},
+			want: want{
+				err: errors.Wrap(errBoom, errGetSigningSecret),
+			},
+		},
+		"SecretMissingIsCreated": {
+			reason: "Should generate and create a new CA if the secret doesn't exist.",
+			args: args{
+				client: &test.MockClient{
+					MockGet:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+					MockCreate: test.NewMockCreateFn(nil),
+				},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ExistingCertNotYetDueForRotation": {
+			reason: "Should return the existing CA unchanged if it isn't close to expiring.",
+			args: args{
+				client: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj client.Object) error {
+						ca, err := newSelfSignedCA("existing", 365*24*time.Hour)
+						if err != nil {
+							t.Fatalf("newSelfSignedCA(...): unexpected error: %v", err)
+						}
+						*obj.(*corev1.Secret) = corev1.Secret{
+							Data: map[string][]byte{keyCACert: ca.Cert, keyCAKey: ca.Key},
+						}
+						return nil
+					}),
+				},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &RotatedSigningCASecret{Client: tc.args.client, Validity: 365 * 24 * time.Hour}
+			_, err := r.Sync(context.Background())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nr.Sync(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
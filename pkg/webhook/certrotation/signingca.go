@@ -0,0 +1,163 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package certrotation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errGetSigningSecret    = "cannot get signing CA secret"
+	errCreateSigningSecret = "cannot create signing CA secret"
+	errUpdateSigningSecret = "cannot update signing CA secret"
+	errGenerateSigningCert = "cannot generate signing CA certificate"
+	errParseSigningCert    = "cannot parse signing CA certificate"
+
+	keyCACert = corev1.TLSCertKey
+	keyCAKey  = corev1.TLSPrivateKeyKey
+)
+
+// CA is a signing certificate authority's public certificate and private
+// key, PEM encoded.
+type CA struct {
+	Cert []byte
+	Key  []byte
+}
+
+// RotatedSigningCASecret manages a self-signed signing CA stored in a
+// corev1.Secret, regenerating it once it is within a quarter of its
+// validity of expiring.
+type RotatedSigningCASecret struct {
+	Client     client.Client
+	Namespace  string
+	SecretName string
+	Validity   time.Duration
+}
+
+// Sync returns the current signing CA, generating a new one and persisting
+// it to the backing Secret if none exists yet or the existing one is close
+// to expiring.
+func (r *RotatedSigningCASecret) Sync(ctx context.Context) (CA, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: r.Namespace, Name: r.SecretName}
+	err := r.Client.Get(ctx, key, secret)
+	switch {
+	case kerrors.IsNotFound(err):
+		return r.rotate(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: r.SecretName, Namespace: r.Namespace}})
+	case err != nil:
+		return CA{}, errors.Wrap(err, errGetSigningSecret)
+	}
+
+	needsRotation, err := r.needsRotation(secret)
+	if err != nil {
+		return CA{}, err
+	}
+	if !needsRotation {
+		return CA{Cert: secret.Data[keyCACert], Key: secret.Data[keyCAKey]}, nil
+	}
+
+	return r.rotate(ctx, secret)
+}
+
+func (r *RotatedSigningCASecret) needsRotation(secret *corev1.Secret) (bool, error) {
+	certPEM := secret.Data[keyCACert]
+	if len(certPEM) == 0 {
+		return true, nil
+	}
+
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return false, errors.Wrap(err, errParseSigningCert)
+	}
+
+	// Rotate once we're within a quarter of the CA's validity window of
+	// expiring, so the bundle has time to propagate before clients stop
+	// trusting the leaf it eventually signs.
+	return time.Now().After(cert.NotAfter.Add(-r.Validity / 4)), nil
+}
+
+func (r *RotatedSigningCASecret) rotate(ctx context.Context, secret *corev1.Secret) (CA, error) {
+	ca, err := newSelfSignedCA(r.SecretName, r.Validity)
+	if err != nil {
+		return CA{}, errors.Wrap(err, errGenerateSigningCert)
+	}
+
+	create := secret.ResourceVersion == ""
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Type = corev1.SecretTypeTLS
+	secret.Data[keyCACert] = ca.Cert
+	secret.Data[keyCAKey] = ca.Key
+
+	if create {
+		if err := r.Client.Create(ctx, secret); err != nil {
+			return CA{}, errors.Wrap(err, errCreateSigningSecret)
+		}
+		return ca, nil
+	}
+	if err := r.Client.Update(ctx, secret); err != nil {
+		return CA{}, errors.Wrap(err, errUpdateSigningSecret)
+	}
+	return ca, nil
+}
+
+// newSelfSignedCA generates a new self-signed CA certificate and key pair,
+// PEM encoded.
+func newSelfSignedCA(cn string, validity time.Duration) (CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return CA{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return CA{}, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return CA{}, err
+	}
+
+	return CA{Cert: encodeCertificate(der), Key: encodeECKey(key)}, nil
+}
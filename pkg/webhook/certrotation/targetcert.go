@@ -0,0 +1,169 @@
+/*
+ Copyright 2022 The Crossplane Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package certrotation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+)
+
+const (
+	errGetServingSecret    = "cannot get serving certificate secret"
+	errCreateServingSecret = "cannot create serving certificate secret"
+	errUpdateServingSecret = "cannot update serving certificate secret"
+	errParseServingCert    = "cannot parse serving certificate"
+	errParseSigningKey     = "cannot parse signing CA key"
+	errGenerateServingCert = "cannot generate serving certificate"
+)
+
+// TargetCertKeyPair manages the serving certificate and key handed to
+// controller-runtime's webhook server, minting a new leaf whenever the
+// signing CA that issued the current one changes, or the current one is
+// close to expiring.
+type TargetCertKeyPair struct {
+	Client     client.Client
+	Namespace  string
+	SecretName string
+	DNSNames   []string
+	Validity   time.Duration
+}
+
+// Sync ensures the backing Secret holds a current serving certificate,
+// (re)issuing it from ca and persisting it if needed.
+func (t *TargetCertKeyPair) Sync(ctx context.Context, ca CA) error {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: t.Namespace, Name: t.SecretName}
+	err := t.Client.Get(ctx, key, secret)
+	switch {
+	case kerrors.IsNotFound(err):
+		return t.issue(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: t.SecretName, Namespace: t.Namespace}}, ca)
+	case err != nil:
+		return errors.Wrap(err, errGetServingSecret)
+	}
+
+	needsIssue, err := t.needsIssue(secret, ca)
+	if err != nil {
+		return err
+	}
+	if !needsIssue {
+		return nil
+	}
+	return t.issue(ctx, secret, ca)
+}
+
+func (t *TargetCertKeyPair) needsIssue(secret *corev1.Secret, ca CA) (bool, error) {
+	certPEM := secret.Data[keyCACert]
+	if len(certPEM) == 0 {
+		return true, nil
+	}
+
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return false, errors.Wrap(err, errParseServingCert)
+	}
+
+	if time.Now().After(cert.NotAfter.Add(-t.Validity / 4)) {
+		return true, nil
+	}
+
+	caCert, err := parseCertificate(ca.Cert)
+	if err != nil {
+		return false, errors.Wrap(err, errParseSigningCert)
+	}
+	// If the leaf wasn't signed by the current CA, the CA must have
+	// rotated since this leaf was issued - reissue from the new one.
+	return cert.CheckSignatureFrom(caCert) != nil, nil
+}
+
+func (t *TargetCertKeyPair) issue(ctx context.Context, secret *corev1.Secret, ca CA) error {
+	caKey, err := parseECKey(ca.Key)
+	if err != nil {
+		return errors.Wrap(err, errParseSigningKey)
+	}
+	caCert, err := parseCertificate(ca.Cert)
+	if err != nil {
+		return errors.Wrap(err, errParseSigningCert)
+	}
+
+	leaf, leafKey, err := newLeafCert(caCert, caKey, t.DNSNames, t.Validity)
+	if err != nil {
+		return errors.Wrap(err, errGenerateServingCert)
+	}
+
+	create := secret.ResourceVersion == ""
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Type = corev1.SecretTypeTLS
+	secret.Data[keyCACert] = leaf
+	secret.Data[keyCAKey] = leafKey
+
+	if create {
+		return errors.Wrap(t.Client.Create(ctx, secret), errCreateServingSecret)
+	}
+	return errors.Wrap(t.Client.Update(ctx, secret), errUpdateServingSecret)
+}
+
+func newLeafCert(caCert *x509.Certificate, caKey *ecdsa.PrivateKey, dnsNames []string, validity time.Duration) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNameOrDefault(dnsNames)},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     dnsNames,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCertificate(der), encodeECKey(key), nil
+}
+
+func dnsNameOrDefault(names []string) string {
+	if len(names) == 0 {
+		return "webhook"
+	}
+	return names[0]
+}
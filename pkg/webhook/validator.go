@@ -25,27 +25,29 @@ import (
 
 var _ webhook.CustomValidator = &Validator{}
 
-// WithValidateCreateFns initializes the Validator with given set of creation
-// validation functions.
+// WithValidateCreateFns appends the given creation validation functions to
+// the Validator's creation chain. It composes with any rules added by
+// WithValidateCreateCEL, regardless of option order.
 func WithValidateCreateFns(fns ...ValidateCreateFn) ValidatorOption {
 	return func(v *Validator) {
-		v.CreationChain = fns
+		v.CreationChain = append(v.CreationChain, fns...)
 	}
 }
 
-// WithValidateUpdateFns initializes the Validator with given set of update
-// validation functions.
+// WithValidateUpdateFns appends the given update validation functions to
+// the Validator's update chain. It composes with any rules added by
+// WithValidateUpdateCEL or WithImmutableFields, regardless of option order.
 func WithValidateUpdateFns(fns ...ValidateUpdateFn) ValidatorOption {
 	return func(v *Validator) {
-		v.UpdateChain = fns
+		v.UpdateChain = append(v.UpdateChain, fns...)
 	}
 }
 
-// WithValidateDeletionFns initializes the Validator with given set of deletion
-// validation functions.
+// WithValidateDeletionFns appends the given deletion validation functions to
+// the Validator's deletion chain.
 func WithValidateDeletionFns(fns ...ValidateDeleteFn) ValidatorOption {
 	return func(v *Validator) {
-		v.DeletionChain = fns
+		v.DeletionChain = append(v.DeletionChain, fns...)
 	}
 }
 
@@ -79,10 +81,19 @@ type Validator struct {
 	CreationChain []ValidateCreateFn
 	UpdateChain   []ValidateUpdateFn
 	DeletionChain []ValidateDeleteFn
+
+	// initErr is set by a ValidatorOption that fails during construction,
+	// e.g. a WithValidateCreateCEL rule that doesn't compile. It's
+	// returned by ValidateCreate and ValidateUpdate, rather than changing
+	// NewValidator's signature; ValidateDelete does not check it.
+	initErr error
 }
 
 // ValidateCreate runs functions in creation chain in order.
 func (vc *Validator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	if vc.initErr != nil {
+		return vc.initErr
+	}
 	for _, f := range vc.CreationChain {
 		if err := f(ctx, obj); err != nil {
 			return err
@@ -93,6 +104,9 @@ func (vc *Validator) ValidateCreate(ctx context.Context, obj runtime.Object) err
 
 // ValidateUpdate runs functions in update chain in order.
 func (vc *Validator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	if vc.initErr != nil {
+		return vc.initErr
+	}
 	for _, f := range vc.UpdateChain {
 		if err := f(ctx, oldObj, newObj); err != nil {
 			return err